@@ -6,6 +6,8 @@ import (
 	"github.com/suvaidkhan/code-explore-mcp/internal/fs"
 	"github.com/suvaidkhan/code-explore-mcp/internal/index"
 	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+	"io"
+	"log"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -23,18 +25,12 @@ type Analyzer struct {
 	lastIndexedAt time.Time
 }
 
-func New(ctx context.Context, workspaceRoot string) (*Analyzer, error) {
-	index, err := index.New(ctx, workspaceRoot)
+func New(ctx context.Context, workspaceRoot string, cfg index.Config) (*Analyzer, error) {
+	analyzer, err := open(ctx, workspaceRoot, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	analyzer := &Analyzer{
-		workspaceRoot: workspaceRoot,
-		parsers:       map[Language]*parser.Parser{},
-		index:         index,
-	}
-
 	go analyzer.IndexWorkspace(ctx)
 
 	w, err := fs.NewWatcher(
@@ -51,6 +47,31 @@ func New(ctx context.Context, workspaceRoot string) (*Analyzer, error) {
 	return analyzer, nil
 }
 
+// Build indexes the workspace once, without watching it for changes
+func Build(ctx context.Context, workspaceRoot string, cfg index.Config) (*Analyzer, error) {
+	analyzer, err := open(ctx, workspaceRoot, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	analyzer.IndexWorkspace(ctx)
+	return analyzer, nil
+}
+
+func open(ctx context.Context, workspaceRoot string, cfg index.Config) (*Analyzer, error) {
+	cfg.Parsers = languages.fingerprints()
+	index, err := index.New(ctx, workspaceRoot, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Analyzer{
+		workspaceRoot: workspaceRoot,
+		parsers:       map[Language]*parser.Parser{},
+		index:         index,
+	}, nil
+}
+
 func (a *Analyzer) IndexWorkspace(ctx context.Context) {
 	a.flushPendingChanges()
 
@@ -64,6 +85,16 @@ func (a *Analyzer) IndexWorkspace(ctx context.Context) {
 	})
 
 	a.processFiles(ctx, filesToProcess)
+	if ctx.Err() != nil {
+		return
+	}
+
+	// Files whose chunks a schema change dropped are only re-indexed by a
+	// full scan, so unused embeddings can't be told apart before
+	err := a.index.PruneEmbeddings()
+	if err != nil {
+		log.Printf("Failed to prune cached embeddings: %v", err)
+	}
 }
 
 func (a *Analyzer) handleFileChange(ctx context.Context, filePaths []string) {
@@ -87,6 +118,11 @@ func (a *Analyzer) processFiles(ctx context.Context, filePaths []string) {
 	a.indexMu.Lock()
 	a.lastIndexedAt = time.Now()
 	a.indexMu.Unlock()
+
+	err := a.index.Save()
+	if err != nil {
+		log.Printf("Failed to save index: %v", err)
+	}
 }
 
 func (a *Analyzer) getParser(filePath string) (*parser.Parser, error) {
@@ -110,6 +146,11 @@ func (a *Analyzer) chunk(ctx context.Context, filePath string) error {
 		return err
 	}
 
+	file.Language = string(languages.detect(filePath))
+	for _, chunk := range file.Chunks {
+		chunk.Language = file.Language
+	}
+
 	err = a.index.Index(ctx, file)
 	if err != nil {
 		return err
@@ -118,14 +159,30 @@ func (a *Analyzer) chunk(ctx context.Context, filePath string) error {
 	return nil
 }
 
-func (a *Analyzer) SemanticSearch(ctx context.Context, query string, fileTypes []string) ([]string, error) {
+func (a *Analyzer) SemanticSearch(ctx context.Context, query string, opts index.SearchOptions) (*index.SearchPage, error) {
+	a.flushPendingChanges()
+	return a.index.Search(ctx, query, opts)
+}
+
+func (a *Analyzer) FindSimilarChunks(ctx context.Context, chunkID string, opts index.SearchOptions) (*index.SearchPage, error) {
+	a.flushPendingChanges()
+	return a.index.FindSimilarChunks(ctx, chunkID, opts)
+}
+
+func (a *Analyzer) FindSymbol(ctx context.Context, name string, match index.SymbolMatch) ([]*index.SymbolResult, error) {
 	a.flushPendingChanges()
-	return a.index.Search(ctx, query, fileTypes)
+	return a.index.FindSymbol(ctx, name, match)
 }
 
-func (a *Analyzer) FindSimilarChunks(ctx context.Context, chunkID string) ([]string, error) {
+func (a *Analyzer) GrepChunks(
+	ctx context.Context,
+	pattern string,
+	literal bool,
+	ignoreCase bool,
+	fileTypes []string,
+) ([]string, error) {
 	a.flushPendingChanges()
-	return a.index.FindSimilarChunks(ctx, chunkID)
+	return a.index.GrepChunks(ctx, pattern, literal, ignoreCase, fileTypes)
 }
 
 func (a *Analyzer) flushPendingChanges() {
@@ -162,6 +219,11 @@ func (a *Analyzer) getSingleChunkCode(ctx context.Context, id string) string {
 	return fmt.Sprintf("== %s ==\n\n%s\n\n", id, chunk.Source)
 }
 
+// ExportIndex writes a snapshot of the index, see index.Index.Export
+func (a *Analyzer) ExportIndex(ctx context.Context, w io.Writer) (*index.Snapshot, error) {
+	return a.index.Export(ctx, w)
+}
+
 func (a *Analyzer) GetIndexStatus() (int, time.Time) {
 	a.indexMu.RLock()
 	pendingFiles := a.nPendingFiles
@@ -175,6 +237,12 @@ func (a *Analyzer) GetIndexStatus() (int, time.Time) {
 	return pendingFiles, lastIndexedAt
 }
 
+// QuantizationRecall returns the measured recall of quantized searches, see
+// index.Index.QuantizationRecall
+func (a *Analyzer) QuantizationRecall() (float64, bool) {
+	return a.index.QuantizationRecall()
+}
+
 func (a *Analyzer) Close() {
 	if a.watcher != nil {
 		a.watcher.Close()
@@ -183,4 +251,9 @@ func (a *Analyzer) Close() {
 	for _, parser := range a.parsers {
 		parser.Close()
 	}
+
+	err := a.index.Close()
+	if err != nil {
+		log.Printf("Failed to close index: %v", err)
+	}
 }
@@ -22,6 +22,7 @@ type ParserFactory func(workspaceRoot string) (*parser.Parser, error)
 type registry struct {
 	extensions map[string]Language
 	factories  map[Language]ParserFactory
+	specs      map[Language]*parser.LanguageSpec
 }
 
 func (r *registry) supportedExts() []string {
@@ -51,8 +52,19 @@ func (r *registry) createParser(workspaceRoot string, lang Language) (*parser.Pa
 	return factory(workspaceRoot)
 }
 
-func (r *registry) register(lang Language, extensions []string, factory ParserFactory) {
+// fingerprints returns the parser spec fingerprint of each language
+func (r *registry) fingerprints() map[string]string {
+	fingerprints := make(map[string]string, len(r.specs))
+	for lang, spec := range r.specs {
+		fingerprints[string(lang)] = spec.Fingerprint()
+	}
+
+	return fingerprints
+}
+
+func (r *registry) register(lang Language, extensions []string, spec *parser.LanguageSpec, factory ParserFactory) {
 	r.factories[lang] = factory
+	r.specs[lang] = spec
 	for _, ext := range extensions {
 		r.extensions[ext] = lang
 	}
@@ -61,12 +73,14 @@ func (r *registry) register(lang Language, extensions []string, factory ParserFa
 var languages = &registry{
 	extensions: map[string]Language{},
 	factories:  map[Language]ParserFactory{},
+	specs:      map[Language]*parser.LanguageSpec{},
 }
 
 func init() {
 	languages.register(
 		Go,
 		[]string{".go"},
+		parser.GoSpec,
 		func(workspaceRoot string) (*parser.Parser, error) {
 			return parser.NewGoParser(workspaceRoot)
 		},
@@ -75,6 +89,7 @@ func init() {
 	languages.register(
 		JavaScript,
 		[]string{".js", ".jsx", ".mjs"},
+		parser.JavaScriptSpec,
 		func(workspaceRoot string) (*parser.Parser, error) {
 			return parser.NewJavaScriptParser(workspaceRoot)
 		},
@@ -83,6 +98,7 @@ func init() {
 	languages.register(
 		Python,
 		[]string{".py"},
+		parser.PythonSpec,
 		func(workspaceRoot string) (*parser.Parser, error) {
 			return parser.NewPythonParser(workspaceRoot)
 		},
@@ -91,6 +107,7 @@ func init() {
 	languages.register(
 		TypeScript,
 		[]string{".ts", ".tsx"},
+		parser.TypeScriptSpec,
 		func(workspaceRoot string) (*parser.Parser, error) {
 			return parser.NewTypeScriptParser(workspaceRoot)
 		},
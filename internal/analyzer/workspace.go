@@ -0,0 +1,465 @@
+package analyzer
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/index"
+)
+
+// repoSeparator separates the repository alias from the rest of a chunk ID,
+// e.g. "api:handlers/user.go::CreateUser"
+const repoSeparator = ":"
+
+// Repo is a workspace root indexed under an alias
+type Repo struct {
+	Alias string
+	Root  string
+}
+
+// ReposFromEnv reads the repositories to index from CODE_SEARCH_WORKSPACE_ROOTS,
+// a comma-separated list of alias=path or path entries, falling back to the
+// single CODE_SEARCH_WORKSPACE_ROOT
+func ReposFromEnv() ([]Repo, error) {
+	roots := os.Getenv("CODE_SEARCH_WORKSPACE_ROOTS")
+	if roots == "" {
+		root := os.Getenv("CODE_SEARCH_WORKSPACE_ROOT")
+		if root == "" {
+			root = "."
+		}
+
+		return []Repo{{Root: root}}, nil
+	}
+
+	return ParseRepos(roots)
+}
+
+// ParseRepos parses a comma-separated list of alias=path or path entries, paths
+// without an alias are aliased by their base name
+func ParseRepos(spec string) ([]Repo, error) {
+	var repos []Repo
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		alias, root, found := strings.Cut(entry, "=")
+		if !found {
+			root = entry
+			abs, err := filepath.Abs(root)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve workspace root: %w", err)
+			}
+			alias = filepath.Base(abs)
+		}
+
+		// Aliases name the repositories' db dirs under a shared absolute db path
+		if alias == "" || alias == "." || alias == ".." || strings.ContainsAny(alias, repoSeparator+`/\`) {
+			return nil, fmt.Errorf("invalid repository alias: %q", alias)
+		}
+
+		if seen[alias] {
+			return nil, fmt.Errorf("duplicate repository alias: %s", alias)
+		}
+		seen[alias] = true
+
+		repos = append(repos, Repo{Alias: alias, Root: root})
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no workspace roots given")
+	}
+
+	return repos, nil
+}
+
+// Workspace indexes one or more repositories, each with its own analyzer &
+// index, and searches across them. With several repositories chunk IDs are
+// prefixed with the repository alias, e.g. "api:handlers/user.go::CreateUser".
+type Workspace struct {
+	aliases   []string // in configuration order
+	analyzers map[string]*Analyzer
+}
+
+func NewWorkspace(ctx context.Context, repos []Repo, cfg index.Config) (*Workspace, error) {
+	w := &Workspace{analyzers: map[string]*Analyzer{}}
+
+	// A single repository keeps unprefixed IDs
+	if len(repos) == 1 {
+		repos = []Repo{{Root: repos[0].Root}}
+	}
+
+	for _, repo := range repos {
+		repoCfg := cfg
+		if repo.Alias != "" && filepath.IsAbs(cfg.Storage.Path) {
+			// Repositories sharing a db dir would delete each other's files
+			repoCfg.Storage.Path = filepath.Join(cfg.Storage.Path, repo.Alias)
+		}
+
+		a, err := New(ctx, repo.Root, repoCfg)
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("failed to open repository %s: %w", repo.Root, err)
+		}
+
+		w.aliases = append(w.aliases, repo.Alias)
+		w.analyzers[repo.Alias] = a
+	}
+
+	return w, nil
+}
+
+// Repos returns the repository aliases, empty for a single repository
+func (w *Workspace) Repos() []string {
+	if len(w.aliases) == 1 {
+		return nil
+	}
+
+	return slices.Clone(w.aliases)
+}
+
+// resolve splits a chunk ID into the analyzer of its repository & the ID within it
+func (w *Workspace) resolve(id string) (*Analyzer, string, error) {
+	if len(w.aliases) == 1 {
+		return w.analyzers[w.aliases[0]], id, nil
+	}
+
+	alias, chunkID, found := strings.Cut(id, repoSeparator)
+	a, exists := w.analyzers[alias]
+	if !found || !exists {
+		return nil, "", fmt.Errorf("chunk ID has no known repository prefix: %s", id)
+	}
+
+	return a, chunkID, nil
+}
+
+// prefix qualifies an ID or result line with the repository alias
+func prefix(alias, s string) string {
+	if alias == "" {
+		return s
+	}
+
+	return alias + repoSeparator + s
+}
+
+// SemanticSearch searches the given repositories, or all when none are given,
+// merging their results by rank
+func (w *Workspace) SemanticSearch(
+	ctx context.Context,
+	query string,
+	repos []string,
+	opts index.SearchOptions,
+) (*index.SearchPage, error) {
+	// The merged page is cut like the repositories' own pages
+	if opts.Limit <= 0 {
+		opts.Limit = index.DefaultLimit
+	}
+	opts.Limit = min(opts.Limit, index.MaxLimit)
+	opts.Offset = max(opts.Offset, 0)
+
+	aliases := w.aliases
+	if len(repos) > 0 && len(w.aliases) > 1 {
+		for _, alias := range repos {
+			if _, exists := w.analyzers[alias]; !exists {
+				return nil, fmt.Errorf("unknown repository: %s", alias)
+			}
+		}
+		aliases = repos
+	}
+
+	if len(aliases) == 1 {
+		page, err := w.analyzers[aliases[0]].SemanticSearch(ctx, query, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		qualify(aliases[0], page.Results)
+		return page, nil
+	}
+
+	var (
+		rankings = make([][]*index.SearchResult, len(aliases))
+		hasMore  bool
+		firstErr error
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+	)
+
+	// Each repository ranks its chunks independently, so the merged page needs
+	// the top offset+limit results of every repository
+	for i, alias := range aliases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			results, more, err := w.collect(ctx, alias, query, opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			rankings[i] = results
+			hasMore = hasMore || more
+		}()
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	merged := mergeByRank(rankings)
+
+	page := &index.SearchPage{Results: []*index.SearchResult{}}
+	if opts.Offset < len(merged) {
+		end := min(opts.Offset+opts.Limit, len(merged))
+		page.Results = merged[opts.Offset:end]
+		if end < len(merged) || hasMore {
+			page.NextOffset = end
+		}
+	}
+
+	return page, nil
+}
+
+// SearchFiles searches like SemanticSearch, grouping the top ranked chunks by
+// file & paging the files instead of the chunks
+func (w *Workspace) SearchFiles(
+	ctx context.Context,
+	query string,
+	repos []string,
+	opts index.SearchOptions,
+) (*index.FilePage, error) {
+	chunkOpts := opts
+	chunkOpts.Offset = 0
+	chunkOpts.Limit = index.MaxLimit
+
+	page, err := w.SemanticSearch(ctx, query, repos, chunkOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	return index.GroupFiles(page.Results, opts), nil
+}
+
+// collect pages through a repository's results until it has enough to fill
+// the requested page of the merged results, reporting whether more follow
+func (w *Workspace) collect(
+	ctx context.Context,
+	alias, query string,
+	opts index.SearchOptions,
+) ([]*index.SearchResult, bool, error) {
+	n := opts.Offset + opts.Limit
+	repoOpts := opts
+	repoOpts.Offset = 0
+
+	var results []*index.SearchResult
+	for {
+		page, err := w.analyzers[alias].SemanticSearch(ctx, query, repoOpts)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to search %s: %w", alias, err)
+		}
+
+		qualify(alias, page.Results)
+		results = append(results, page.Results...)
+		if page.NextOffset == 0 || len(results) >= n {
+			return results, page.NextOffset > 0, nil
+		}
+
+		repoOpts.Offset = page.NextOffset
+	}
+}
+
+// qualify prefixes result IDs with the repository alias
+func qualify(alias string, results []*index.SearchResult) {
+	if alias == "" {
+		return
+	}
+
+	for _, result := range results {
+		result.ID = prefix(alias, result.ID)
+		result.Repo = alias
+	}
+}
+
+// mergeByRank interleaves ranked result lists by rank, like reciprocal rank
+// fusion of lists that share no results. Each list is ranked by fused relevance
+// rather than by score, so scores only break ties between equal ranks
+func mergeByRank(rankings [][]*index.SearchResult) []*index.SearchResult {
+	var merged []*index.SearchResult
+	for rank := 0; ; rank++ {
+		var tied []*index.SearchResult
+		for _, ranking := range rankings {
+			if rank < len(ranking) {
+				tied = append(tied, ranking[rank])
+			}
+		}
+
+		if len(tied) == 0 {
+			return merged
+		}
+
+		// Stable, so equal scores keep the repository order
+		slices.SortStableFunc(tied, func(a, b *index.SearchResult) int {
+			return cmp.Compare(b.Score, a.Score)
+		})
+		merged = append(merged, tied...)
+	}
+}
+
+// FindSimilarChunks finds chunks similar to the given chunk within its repository
+func (w *Workspace) FindSimilarChunks(ctx context.Context, id string, opts index.SearchOptions) (*index.SearchPage, error) {
+	a, chunkID, err := w.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := a.FindSimilarChunks(ctx, chunkID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	qualify(w.aliasOf(a), page.Results)
+	return page, nil
+}
+
+// FindSymbol looks up a symbol in every repository, closest matches first
+func (w *Workspace) FindSymbol(ctx context.Context, name string, match index.SymbolMatch) ([]string, error) {
+	var found []*index.SymbolResult
+	for _, alias := range w.aliases {
+		results, err := w.analyzers[alias].FindSymbol(ctx, name, match)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results {
+			qualify(alias, []*index.SearchResult{result.SearchResult})
+		}
+		found = append(found, results...)
+	}
+
+	// Stable, so equally close matches keep the repository order
+	slices.SortStableFunc(found, index.CompareSymbolResults)
+	if len(found) > index.DefaultLimit {
+		found = found[:index.DefaultLimit]
+	}
+
+	lines := make([]string, 0, len(found))
+	for _, result := range found {
+		lines = append(lines, result.String())
+	}
+
+	return lines, nil
+}
+
+// GrepChunks greps the chunks of every repository
+func (w *Workspace) GrepChunks(
+	ctx context.Context,
+	pattern string,
+	literal bool,
+	ignoreCase bool,
+	fileTypes []string,
+) ([]string, error) {
+	results := []string{}
+	for _, alias := range w.aliases {
+		found, err := w.analyzers[alias].GrepChunks(ctx, pattern, literal, ignoreCase, fileTypes)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range found {
+			results = append(results, prefix(alias, line))
+		}
+	}
+
+	if len(results) > index.MaxGrepMatches {
+		results = results[:index.MaxGrepMatches]
+	}
+
+	return results, nil
+}
+
+func (w *Workspace) GetChunkCode(ctx context.Context, ids []string) string {
+	result := ""
+	for _, id := range ids {
+		a, chunkID, err := w.resolve(id)
+		if err != nil {
+			result += fmt.Sprintf("== %s ==\n\n<invalid chunk id>\n\n", id)
+			continue
+		}
+
+		// Keep the qualified ID in the header
+		code := a.getSingleChunkCode(ctx, chunkID)
+		result += strings.Replace(code, "== "+chunkID+" ==", "== "+id+" ==", 1)
+	}
+
+	return result
+}
+
+func (w *Workspace) IndexWorkspace(ctx context.Context) {
+	for _, alias := range w.aliases {
+		w.analyzers[alias].IndexWorkspace(ctx)
+	}
+}
+
+// GetIndexStatus sums the pending files of all repositories, the last indexed
+// time is zero while any repository is still being indexed
+func (w *Workspace) GetIndexStatus() (int, time.Time) {
+	var pendingFiles int
+	var lastIndexedAt time.Time
+	for i, alias := range w.aliases {
+		pending, indexedAt := w.analyzers[alias].GetIndexStatus()
+		pendingFiles += pending
+
+		if indexedAt.IsZero() {
+			lastIndexedAt = time.Time{}
+		} else if i == 0 || !lastIndexedAt.IsZero() && indexedAt.Before(lastIndexedAt) {
+			lastIndexedAt = indexedAt
+		}
+	}
+
+	return pendingFiles, lastIndexedAt
+}
+
+// QuantizationRecall returns the measured recall of quantized searches per
+// repository alias, repositories without a measurement are left out
+func (w *Workspace) QuantizationRecall() map[string]float64 {
+	recalls := map[string]float64{}
+	for _, alias := range w.aliases {
+		if recall, ok := w.analyzers[alias].QuantizationRecall(); ok {
+			recalls[alias] = recall
+		}
+	}
+
+	return recalls
+}
+
+func (w *Workspace) aliasOf(a *Analyzer) string {
+	for alias, analyzer := range w.analyzers {
+		if analyzer == a {
+			return alias
+		}
+	}
+
+	return ""
+}
+
+func (w *Workspace) Close() {
+	for _, a := range w.analyzers {
+		a.Close()
+	}
+}
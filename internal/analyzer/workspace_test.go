@@ -0,0 +1,218 @@
+package analyzer
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/index"
+)
+
+func TestParseRepos(t *testing.T) {
+	tests := []struct {
+		spec   string
+		repos  []Repo
+		failed bool
+	}{
+		{spec: "api=../api, web=/src/web", repos: []Repo{{"api", "../api"}, {"web", "/src/web"}}},
+		{spec: "/src/api,,", repos: []Repo{{"api", "/src/api"}}},
+		{spec: "", failed: true},
+		{spec: "api=../api,api=../other", failed: true},
+		{spec: "=../api", failed: true},
+		{spec: "a:b=../api", failed: true},
+		{spec: "a/b=../api", failed: true},
+		{spec: "..=../api", failed: true},
+	}
+
+	for _, test := range tests {
+		repos, err := ParseRepos(test.spec)
+		if (err != nil) != test.failed {
+			t.Errorf("parsing %q returned %v", test.spec, err)
+			continue
+		}
+
+		if !slices.Equal(repos, test.repos) {
+			t.Errorf("parsed %q as %v, expected %v", test.spec, repos, test.repos)
+		}
+	}
+}
+
+func TestMergeByRank(t *testing.T) {
+	ranking := func(alias string, scores ...float32) []*index.SearchResult {
+		results := make([]*index.SearchResult, 0, len(scores))
+		for i, score := range scores {
+			results = append(results, &index.SearchResult{ID: alias + string(rune('a'+i)), Score: score})
+		}
+		return results
+	}
+
+	tests := []struct {
+		name     string
+		rankings [][]*index.SearchResult
+		ids      []string
+	}{
+		{
+			name:     "interleaved by rank, higher score first",
+			rankings: [][]*index.SearchResult{ranking("x", 0.5, 0.4), ranking("y", 0.9, 0.1)},
+			ids:      []string{"ya", "xa", "xb", "yb"},
+		},
+		{
+			name:     "ties keep the repository order",
+			rankings: [][]*index.SearchResult{ranking("x", 0.5), ranking("y", 0.5)},
+			ids:      []string{"xa", "ya"},
+		},
+		{
+			name:     "uneven lengths",
+			rankings: [][]*index.SearchResult{ranking("x", 0.5), ranking("y", 0.2, 0.9, 0.8)},
+			ids:      []string{"xa", "ya", "yb", "yc"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ids := []string{}
+			for _, result := range mergeByRank(test.rankings) {
+				ids = append(ids, result.ID)
+			}
+
+			if !slices.Equal(ids, test.ids) {
+				t.Errorf("merged %v, expected %v", ids, test.ids)
+			}
+		})
+	}
+}
+
+// buildWorkspace indexes repositories of Go files, by alias & file path, with
+// offline embeddings & without watching them
+func buildWorkspace(t *testing.T, repos map[string]map[string]string) *Workspace {
+	t.Helper()
+
+	w := &Workspace{analyzers: map[string]*Analyzer{}}
+	t.Cleanup(w.Close)
+
+	for _, alias := range slices.Sorted(maps.Keys(repos)) {
+		root := t.TempDir()
+		for filePath, source := range repos[alias] {
+			err := os.WriteFile(filepath.Join(root, filePath), []byte(source), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		a, err := Build(context.Background(), root, index.Config{Embedder: index.EmbedderConfig{Provider: index.ProviderOffline}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.aliases = append(w.aliases, alias)
+		w.analyzers[alias] = a
+	}
+
+	return w
+}
+
+func TestWorkspaceQualifiesIDs(t *testing.T) {
+	ctx := context.Background()
+	w := buildWorkspace(t, map[string]map[string]string{
+		"api": {"users.go": "package api\n\nfunc CreateUser() error {\n\treturn nil\n}\n"},
+		"web": {"users.go": "package web\n\nfunc CreateUser() error {\n\treturn submitForm()\n}\n"},
+	})
+
+	if repos := w.Repos(); !slices.Equal(repos, []string{"api", "web"}) {
+		t.Errorf("repos %v", repos)
+	}
+
+	tests := []struct {
+		name   string
+		repos  []string
+		ids    []string
+		failed bool
+	}{
+		{name: "all repositories", ids: []string{"api:users.go::CreateUser", "web:users.go::CreateUser"}},
+		{name: "one repository", repos: []string{"web"}, ids: []string{"web:users.go::CreateUser"}},
+		{name: "unknown repository", repos: []string{"cli"}, failed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Paging options are normalized like a single repository's
+			opts := index.SearchOptions{FileTypes: []string{"src"}, Offset: -1}
+			page, err := w.SemanticSearch(ctx, "CreateUser", test.repos, opts)
+			if (err != nil) != test.failed {
+				t.Fatalf("search returned %v", err)
+			}
+			if test.failed {
+				return
+			}
+
+			ids := []string{}
+			for _, result := range page.Results {
+				ids = append(ids, result.ID)
+				if !strings.HasPrefix(result.ID, result.Repo+repoSeparator) {
+					t.Errorf("result %s of repository %q", result.ID, result.Repo)
+				}
+			}
+
+			slices.Sort(ids)
+			if !slices.Equal(ids, test.ids) {
+				t.Errorf("found %v, expected %v", ids, test.ids)
+			}
+		})
+	}
+
+	symbols, err := w.FindSymbol(ctx, "CreateUser", index.SymbolMatchExact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != 2 || !strings.HasPrefix(symbols[0], "api:users.go::CreateUser") || !strings.HasPrefix(symbols[1], "web:users.go::CreateUser") {
+		t.Errorf("found symbols %q", symbols)
+	}
+
+	grepped, err := w.GrepChunks(ctx, "submitForm", true, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grepped) != 1 || !strings.HasPrefix(grepped[0], "web:users.go::CreateUser |") {
+		t.Errorf("grep matched %q", grepped)
+	}
+
+	code := w.GetChunkCode(ctx, []string{"web:users.go::CreateUser", "cli:users.go::CreateUser"})
+	if !strings.Contains(code, "== web:users.go::CreateUser ==\n\nfunc CreateUser() error {\n\treturn submitForm()\n}") {
+		t.Errorf("chunk code of a qualified ID:\n%s", code)
+	}
+	if !strings.Contains(code, "== cli:users.go::CreateUser ==\n\n<invalid chunk id>") {
+		t.Errorf("chunk code of an unknown repository:\n%s", code)
+	}
+
+	similar, err := w.FindSimilarChunks(ctx, "api:users.go::CreateUser", index.SearchOptions{MinScore: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range similar.Results {
+		if result.Repo != "api" {
+			t.Errorf("similar chunk %s is from another repository", result.ID)
+		}
+	}
+}
+
+func TestWorkspaceSingleRepoKeepsIDs(t *testing.T) {
+	w := buildWorkspace(t, map[string]map[string]string{
+		"": {"users.go": "package api\n\nfunc CreateUser() error {\n\treturn nil\n}\n"},
+	})
+
+	if repos := w.Repos(); repos != nil {
+		t.Errorf("repos %v of a single repository", repos)
+	}
+
+	page, err := w.SemanticSearch(context.Background(), "CreateUser", nil, index.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Results) == 0 || page.Results[0].ID != "users.go::CreateUser" || page.Results[0].Repo != "" {
+		t.Errorf("found %+v", page.Results)
+	}
+}
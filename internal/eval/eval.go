@@ -0,0 +1,217 @@
+// Package eval measures search quality against golden query sets, so changes
+// to chunking or ranking can be compared & gated on
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/index"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultK is the number of results judged per query
+const DefaultK = 10
+
+// GoldenSet is a set of queries with the chunks they're expected to find
+type GoldenSet struct {
+	K          int        `json:"k,omitempty" yaml:"k,omitempty"` // results judged per query
+	Thresholds Thresholds `json:"thresholds" yaml:"thresholds"`
+	Queries    []Query    `json:"queries" yaml:"queries"`
+}
+
+// Query is a search query & the IDs of the chunks it should find, in any order
+type Query struct {
+	Query     string   `json:"query" yaml:"query"`
+	Expected  []string `json:"expected" yaml:"expected"`
+	FileTypes []string `json:"file_types,omitempty" yaml:"file_types,omitempty"` // defaults to src & docs, like semantic_search
+}
+
+// Thresholds are the minimum mean scores a run must reach, zero disables a check
+type Thresholds struct {
+	Recall float64 `json:"recall,omitempty" yaml:"recall,omitempty"`
+	MRR    float64 `json:"mrr,omitempty" yaml:"mrr,omitempty"`
+	NDCG   float64 `json:"ndcg,omitempty" yaml:"ndcg,omitempty"`
+}
+
+// Searcher runs a search, e.g. analyzer.Analyzer.SemanticSearch
+type Searcher func(ctx context.Context, query string, opts index.SearchOptions) (*index.SearchPage, error)
+
+// Result scores the results of a query
+type Result struct {
+	Query          string   `json:"query"`
+	Recall         float64  `json:"recall"`          // share of expected chunks in the top k
+	ReciprocalRank float64  `json:"reciprocal_rank"` // 1 / rank of the first expected chunk, 0 if none is in the top k
+	NDCG           float64  `json:"ndcg"`            // normalized discounted cumulative gain of the top k
+	Missing        []string `json:"missing,omitempty"`
+}
+
+// Report holds the per-query scores of a run & their means
+type Report struct {
+	K       int      `json:"k"`
+	Recall  float64  `json:"recall"`
+	MRR     float64  `json:"mrr"`
+	NDCG    float64  `json:"ndcg"`
+	Results []Result `json:"results"`
+}
+
+// Load reads a golden set from a YAML file if it has a .yaml or .yml extension,
+// from a JSON file otherwise
+func Load(path string) (*GoldenSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read golden set: %w", err)
+	}
+
+	var set GoldenSet
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &set)
+	default:
+		err = json.Unmarshal(data, &set)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode golden set: %w", err)
+	}
+
+	if len(set.Queries) == 0 {
+		return nil, fmt.Errorf("golden set has no queries")
+	}
+
+	for i, query := range set.Queries {
+		if query.Query == "" || len(query.Expected) == 0 {
+			return nil, fmt.Errorf("query %d needs a query & expected chunk IDs", i+1)
+		}
+	}
+
+	if set.K <= 0 {
+		set.K = DefaultK
+	}
+
+	return &set, nil
+}
+
+// Run searches every query of the set & scores the top k results
+func Run(ctx context.Context, set *GoldenSet, search Searcher) (*Report, error) {
+	report := &Report{K: set.K, Results: make([]Result, 0, len(set.Queries))}
+	for _, query := range set.Queries {
+		// Rank like semantic_search, only the page size & file types differ
+		opts := index.DefaultSearchOptions()
+		opts.Limit = set.K
+		if len(query.FileTypes) > 0 {
+			opts.FileTypes = query.FileTypes
+		}
+
+		page, err := search(ctx, query.Query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %q: %w", query.Query, err)
+		}
+
+		ids := make([]string, 0, len(page.Results))
+		for _, result := range page.Results {
+			ids = append(ids, result.ID)
+		}
+
+		result := score(query, ids, set.K)
+		report.Results = append(report.Results, result)
+		report.Recall += result.Recall
+		report.MRR += result.ReciprocalRank
+		report.NDCG += result.NDCG
+	}
+
+	n := float64(len(report.Results))
+	report.Recall /= n
+	report.MRR /= n
+	report.NDCG /= n
+
+	return report, nil
+}
+
+// score judges the ranked result IDs of a query, every expected chunk is
+// equally relevant
+func score(query Query, ids []string, k int) Result {
+	if len(ids) > k {
+		ids = ids[:k]
+	}
+
+	result := Result{Query: query.Query}
+
+	var hits int
+	var dcg float64
+	for i, id := range ids {
+		if !slices.Contains(query.Expected, id) {
+			continue
+		}
+
+		hits++
+		dcg += 1 / math.Log2(float64(i+2))
+		if result.ReciprocalRank == 0 {
+			result.ReciprocalRank = 1 / float64(i+1)
+		}
+	}
+
+	// The ideal ranking lists every expected chunk first
+	var idcg float64
+	for i := range min(len(query.Expected), k) {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+
+	result.Recall = float64(hits) / float64(len(query.Expected))
+	result.NDCG = dcg / idcg
+
+	for _, id := range query.Expected {
+		if !slices.Contains(ids, id) {
+			result.Missing = append(result.Missing, id)
+		}
+	}
+
+	return result
+}
+
+// Check returns the scores below the thresholds, empty if the run passes
+func (r *Report) Check(thresholds Thresholds) []string {
+	var failures []string
+	for _, check := range []struct {
+		name     string
+		score    float64
+		minScore float64
+	}{
+		{fmt.Sprintf("recall@%d", r.K), r.Recall, thresholds.Recall},
+		{"MRR", r.MRR, thresholds.MRR},
+		{fmt.Sprintf("nDCG@%d", r.K), r.NDCG, thresholds.NDCG},
+	} {
+		if check.score < check.minScore {
+			failures = append(failures, fmt.Sprintf("%s %.3f is below %.3f", check.name, check.score, check.minScore))
+		}
+	}
+
+	return failures
+}
+
+// Print writes the per-query scores, the chunks each query missed & the means
+func (r *Report) Print(w io.Writer) {
+	for _, result := range r.Results {
+		fmt.Fprintf(w, "%-50s recall %.3f  RR %.3f  nDCG %.3f\n", truncate(result.Query, 50), result.Recall, result.ReciprocalRank, result.NDCG)
+		for _, id := range result.Missing {
+			fmt.Fprintf(w, "    missing %s\n", id)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d queries, recall@%d %.3f  MRR %.3f  nDCG@%d %.3f\n", len(r.Results), r.K, r.Recall, r.MRR, r.K, r.NDCG)
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(strings.Join(strings.Fields(s), " "))
+	if len(runes) <= n {
+		return string(runes)
+	}
+
+	return string(runes[:n-3]) + "..."
+}
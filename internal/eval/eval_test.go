@@ -0,0 +1,216 @@
+package eval
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/index"
+)
+
+func TestScore(t *testing.T) {
+	// DCG of a ranking listing two expected chunks first
+	idcg := 1 + 1/math.Log2(3)
+
+	tests := []struct {
+		name     string
+		expected []string
+		ids      []string
+		k        int
+		result   Result
+	}{
+		{
+			name:     "perfect",
+			expected: []string{"a", "b"},
+			ids:      []string{"a", "b", "c"},
+			k:        10,
+			result:   Result{Recall: 1, ReciprocalRank: 1, NDCG: 1},
+		},
+		{
+			name:     "any order of expected chunks",
+			expected: []string{"a", "b"},
+			ids:      []string{"b", "a"},
+			k:        10,
+			result:   Result{Recall: 1, ReciprocalRank: 1, NDCG: 1},
+		},
+		{
+			name:     "second rank",
+			expected: []string{"a", "b"},
+			ids:      []string{"c", "a"},
+			k:        10,
+			result:   Result{Recall: 0.5, ReciprocalRank: 0.5, NDCG: 1 / math.Log2(3) / idcg, Missing: []string{"b"}},
+		},
+		{
+			name:     "beyond k",
+			expected: []string{"a"},
+			ids:      []string{"c", "d", "a"},
+			k:        2,
+			result:   Result{Missing: []string{"a"}},
+		},
+		{
+			name:     "no results",
+			expected: []string{"a", "b"},
+			k:        10,
+			result:   Result{Missing: []string{"a", "b"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := score(Query{Query: test.name, Expected: test.expected}, test.ids, test.k)
+
+			if math.Abs(result.Recall-test.result.Recall) > 1e-9 ||
+				math.Abs(result.ReciprocalRank-test.result.ReciprocalRank) > 1e-9 ||
+				math.Abs(result.NDCG-test.result.NDCG) > 1e-9 ||
+				!slices.Equal(result.Missing, test.result.Missing) {
+				t.Errorf("scored %+v, expected %+v", result, test.result)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	set := &GoldenSet{
+		K: 3,
+		Queries: []Query{
+			{Query: "open store", Expected: []string{"store.go::openStore"}},
+			{Query: "store tests", Expected: []string{"store_test.go::TestOpenStore"}, FileTypes: []string{"tests"}},
+		},
+	}
+
+	ranked := map[string][]string{
+		"open store":  {"store.go::openStore", "store.go::closeStore"},
+		"store tests": {"store.go::openStore", "store_test.go::TestOpenStore"},
+	}
+
+	var searched []index.SearchOptions
+	search := func(ctx context.Context, query string, opts index.SearchOptions) (*index.SearchPage, error) {
+		searched = append(searched, opts)
+
+		page := &index.SearchPage{}
+		for _, id := range ranked[query] {
+			page.Results = append(page.Results, &index.SearchResult{ID: id})
+		}
+		return page, nil
+	}
+
+	report, err := Run(context.Background(), set, search)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.K != 3 || report.Recall != 1 || report.MRR != 0.75 || len(report.Results) != 2 {
+		t.Errorf("report %+v", report)
+	}
+
+	// Queries are searched like semantic_search, with k results per page
+	defaults := index.DefaultSearchOptions()
+	for i, opts := range searched {
+		fileTypes := defaults.FileTypes
+		if len(set.Queries[i].FileTypes) > 0 {
+			fileTypes = set.Queries[i].FileTypes
+		}
+
+		if opts.Limit != set.K || opts.MinScore != defaults.MinScore || !slices.Equal(opts.FileTypes, fileTypes) {
+			t.Errorf("query %d searched with %+v", i+1, opts)
+		}
+	}
+
+	tests := []struct {
+		thresholds Thresholds
+		failures   int
+	}{
+		{Thresholds{}, 0},
+		{Thresholds{Recall: 1, MRR: 0.75}, 0},
+		{Thresholds{MRR: 0.8}, 1},
+		{Thresholds{Recall: 1, MRR: 0.9, NDCG: 0.99}, 2},
+	}
+
+	for _, test := range tests {
+		if failures := report.Check(test.thresholds); len(failures) != test.failures {
+			t.Errorf("thresholds %+v failed %q", test.thresholds, failures)
+		}
+	}
+
+	var printed strings.Builder
+	report.Print(&printed)
+	if !strings.Contains(printed.String(), "2 queries, recall@3 1.000  MRR 0.750") {
+		t.Errorf("printed report:\n%s", printed.String())
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		data   string
+		k      int
+		failed bool
+	}{
+		{
+			name: "json",
+			file: "golden.json",
+			data: `{"k": 5, "thresholds": {"recall": 0.8}, "queries": [{"query": "open store", "expected": ["store.go::openStore"], "file_types": ["src"]}]}`,
+			k:    5,
+		},
+		{
+			name: "yaml",
+			file: "golden.yaml",
+			data: "k: 5\nthresholds:\n  recall: 0.8\nqueries:\n  - query: open store\n    expected: [store.go::openStore]\n    file_types: [src]\n",
+			k:    5,
+		},
+		{
+			name: "yml, default k",
+			file: "golden.yml",
+			data: "thresholds:\n  recall: 0.8\nqueries:\n  - query: open store\n    expected:\n      - store.go::openStore\n    file_types: [src]\n",
+			k:    DefaultK,
+		},
+		{
+			name:   "yaml in a json file",
+			file:   "golden.json",
+			data:   "queries:\n  - query: open store\n    expected: [store.go::openStore]\n",
+			failed: true,
+		},
+		{
+			name:   "no queries",
+			file:   "golden.json",
+			data:   `{"queries": []}`,
+			failed: true,
+		},
+		{
+			name:   "no expected chunks",
+			file:   "golden.yaml",
+			data:   "queries:\n  - query: open store\n",
+			failed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			err := os.WriteFile(path, []byte(test.data), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			set, err := Load(path)
+			if (err != nil) != test.failed {
+				t.Fatalf("loading returned %v", err)
+			}
+			if test.failed {
+				return
+			}
+
+			expected := Query{Query: "open store", Expected: []string{"store.go::openStore"}, FileTypes: []string{"src"}}
+			query := set.Queries[0]
+			if set.K != test.k || set.Thresholds.Recall != 0.8 || len(set.Queries) != 1 ||
+				query.Query != expected.Query || !slices.Equal(query.Expected, expected.Expected) || !slices.Equal(query.FileTypes, expected.FileTypes) {
+				t.Errorf("loaded %+v", set)
+			}
+		})
+	}
+}
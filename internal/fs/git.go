@@ -0,0 +1,38 @@
+package fs
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GitHead returns the commit checked out in the workspace
+func GitHead(workspace string) (string, error) {
+	output, err := git(workspace, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+// GitIsDirty reports whether tracked files in the workspace have uncommitted changes
+func GitIsDirty(workspace string) (bool, error) {
+	output, err := git(workspace, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(output) != "", nil
+}
+
+func git(workspace string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = workspace
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run git %s: %w", args[0], err)
+	}
+
+	return string(output), nil
+}
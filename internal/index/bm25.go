@@ -0,0 +1,147 @@
+package index
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	// bm25PathBoost counts terms in a chunk's path (its symbol names) several
+	// times, so a query naming a symbol ranks its definition above its callers
+	bm25PathBoost = 3
+)
+
+// bm25Index is an in-memory inverted index over chunk paths, summaries and
+// sources, ranking chunks with Okapi BM25
+type bm25Index struct {
+	postings map[string]map[string]int // term -> chunk ID -> term frequency
+	docTerms map[string]map[string]int // chunk ID -> term -> term frequency
+	docLens  map[string]int            // chunk ID -> number of terms
+	totalLen int
+	mu       sync.RWMutex
+}
+
+// scoredID is a chunk ID with its relevance score
+type scoredID struct {
+	id    string
+	score float64
+}
+
+func newBM25Index() *bm25Index {
+	return &bm25Index{
+		postings: map[string]map[string]int{},
+		docTerms: map[string]map[string]int{},
+		docLens:  map[string]int{},
+	}
+}
+
+// add indexes a chunk, replacing any previous entry with the same ID
+func (b *bm25Index) add(id, path, summary, source string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.removeLocked(id)
+
+	terms := tokenize(summary + "\n" + source)
+	pathTerms := tokenize(strings.ReplaceAll(path, "::", " "))
+	for range bm25PathBoost {
+		terms = append(terms, pathTerms...)
+	}
+
+	freqs := map[string]int{}
+	for _, term := range terms {
+		freqs[term]++
+	}
+
+	for term, freq := range freqs {
+		if b.postings[term] == nil {
+			b.postings[term] = map[string]int{}
+		}
+		b.postings[term][id] = freq
+	}
+
+	b.docTerms[id] = freqs
+	b.docLens[id] = len(terms)
+	b.totalLen += len(terms)
+}
+
+// remove drops chunks from the index
+func (b *bm25Index) remove(ids ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, id := range ids {
+		b.removeLocked(id)
+	}
+}
+
+func (b *bm25Index) removeLocked(id string) {
+	freqs, exists := b.docTerms[id]
+	if !exists {
+		return
+	}
+
+	for term := range freqs {
+		delete(b.postings[term], id)
+		if len(b.postings[term]) == 0 {
+			delete(b.postings, term)
+		}
+	}
+
+	b.totalLen -= b.docLens[id]
+	delete(b.docTerms, id)
+	delete(b.docLens, id)
+}
+
+// search returns the chunk IDs matching the query, ranked by their BM25 score
+func (b *bm25Index) search(query string) []scoredID {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	nDocs := len(b.docLens)
+	if nDocs == 0 {
+		return nil
+	}
+	avgLen := float64(b.totalLen) / float64(nDocs)
+
+	scores := map[string]float64{}
+	seen := map[string]bool{}
+	for _, term := range tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := b.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (float64(nDocs)-df+0.5)/(df+0.5))
+		for id, freq := range postings {
+			tf := float64(freq)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(b.docLens[id])/avgLen)
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+
+	results := make([]scoredID, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, scoredID{id: id, score: score})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score == results[j].score {
+			return results[i].id < results[j].id
+		}
+		return results[i].score > results[j].score
+	})
+
+	return results
+}
@@ -0,0 +1,138 @@
+package index
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+)
+
+func TestBM25Search(t *testing.T) {
+	b := newBM25Index()
+	b.add("a.go::parseConfig", "parseConfig", "func parseConfig(path string) (*Config, error)", "return loadYAML(path)")
+	b.add("b.go::main", "main", "func main()", "cfg, err := parseConfig(configPath)")
+	b.add("c.go::openStore", "openStore", "func openStore(dir string) (*Store, error)", "return sqlite.Open(dir)")
+	b.add("d.go::closeStore", "closeStore", "func closeStore(s *Store) error", "return s.Close()")
+
+	tests := []struct {
+		name  string
+		query string
+		ids   []string // expected ranking
+	}{
+		{
+			name:  "definition above callers",
+			query: "parseConfig",
+			ids:   []string{"a.go::parseConfig", "b.go::main"},
+		},
+		{
+			name:  "identifier parts",
+			query: "parse config",
+			ids:   []string{"a.go::parseConfig", "b.go::main"},
+		},
+		{
+			name:  "more terms rank higher",
+			query: "open store",
+			ids:   []string{"c.go::openStore", "d.go::closeStore"},
+		},
+		{
+			name:  "no match",
+			query: "websocket",
+			ids:   []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ids := []string{}
+			for _, hit := range b.search(test.query) {
+				ids = append(ids, hit.id)
+			}
+
+			if !slices.Equal(ids, test.ids) {
+				t.Errorf("ranked %v, expected %v", ids, test.ids)
+			}
+		})
+	}
+}
+
+func TestBM25Remove(t *testing.T) {
+	b := newBM25Index()
+	b.add("a.go::parseConfig", "parseConfig", "func parseConfig()", "")
+	b.add("a.go::parseConfig", "parseConfig", "func parseConfig()", "")
+	b.add("b.go::parseFlags", "parseFlags", "func parseFlags()", "")
+
+	b.remove("a.go::parseConfig", "c.go::missing")
+
+	hits := b.search("parse")
+	if len(hits) != 1 || hits[0].id != "b.go::parseFlags" {
+		t.Errorf("found %v after removing a chunk", hits)
+	}
+	if b.totalLen != b.docLens["b.go::parseFlags"] {
+		t.Errorf("total length %d, expected %d", b.totalLen, b.docLens["b.go::parseFlags"])
+	}
+}
+
+func TestFuseRankings(t *testing.T) {
+	ranking := func(ids ...string) []*SearchResult {
+		results := make([]*SearchResult, 0, len(ids))
+		for _, id := range ids {
+			results = append(results, &SearchResult{ID: id})
+		}
+		return results
+	}
+
+	tests := []struct {
+		name     string
+		maxCount int
+		rankings [][]*SearchResult
+		ids      []string
+	}{
+		{
+			name:     "single ranking",
+			maxCount: 10,
+			rankings: [][]*SearchResult{ranking("a", "b", "c")},
+			ids:      []string{"a", "b", "c"},
+		},
+		{
+			name:     "found by both first",
+			maxCount: 10,
+			rankings: [][]*SearchResult{ranking("a", "b", "c"), ranking("c", "d")},
+			ids:      []string{"c", "a", "b", "d"},
+		},
+		{
+			name:     "ties keep the earlier ranking's order",
+			maxCount: 10,
+			rankings: [][]*SearchResult{ranking("a", "b"), ranking("c", "d")},
+			ids:      []string{"a", "c", "b", "d"},
+		},
+		{
+			name:     "capped",
+			maxCount: 2,
+			rankings: [][]*SearchResult{ranking("a", "b", "c"), ranking("b")},
+			ids:      []string{"b", "a"},
+		},
+		{
+			name:     "empty",
+			maxCount: 10,
+			rankings: [][]*SearchResult{ranking(), ranking()},
+			ids:      []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fused, scores := fuseRankings(test.maxCount, test.rankings...)
+
+			ids := []string{}
+			for _, result := range fused {
+				ids = append(ids, result.ID)
+			}
+			if !slices.Equal(ids, test.ids) {
+				t.Errorf("fused %v, expected %v", ids, test.ids)
+			}
+
+			if len(scores) != len(fused) || !slices.IsSortedFunc(scores, func(a, b float64) int { return cmp.Compare(b, a) }) {
+				t.Errorf("scores %v aren't descending", scores)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/cespare/xxhash"
+	"github.com/philippgille/chromem-go"
+)
+
+const (
+	// embeddingCacheDir holds the cached embeddings in the db dir, a file per
+	// embedding in a subdir per first two hex digits of its key
+	embeddingCacheDir = "embeddings"
+
+	// embeddingCacheCollection held the cache in memory in older indexes
+	embeddingCacheCollection = "embedding-cache"
+)
+
+// embeddingCache is a persistent, content-addressed cache in front of an
+// Embedder. Vectors are keyed by a hash of the model id and the embedded text,
+// so unchanged chunks are never sent to the embedding provider twice, even
+// when they move within or between files. Entries are only read from disk
+// when they're needed, the cache holds no vectors in memory.
+type embeddingCache struct {
+	embedder Embedder
+	dir      string
+}
+
+func newEmbeddingCache(ctx context.Context, db *chromem.DB, dbPath string, embedder Embedder) (*embeddingCache, error) {
+	cache := &embeddingCache{embedder: embedder, dir: filepath.Join(dbPath, embeddingCacheDir)}
+
+	err := os.MkdirAll(cache.dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding cache dir: %w", err)
+	}
+
+	if collection := db.GetCollection(embeddingCacheCollection, nil); collection != nil {
+		err = cache.migrate(ctx, db, collection)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cache, nil
+}
+
+// migrate moves the entries of the in-memory cache of older indexes to disk
+func (c *embeddingCache) migrate(ctx context.Context, db *chromem.DB, collection *chromem.Collection) error {
+	docs, err := collection.ListDocumentsShallow(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list cached embeddings: %w", err)
+	}
+
+	log.Printf("Moving %d cached embeddings to disk", len(docs))
+	for _, doc := range docs {
+		err = c.put(doc.ID, doc.Embedding)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = db.DeleteCollection(embeddingCacheCollection)
+	if err != nil {
+		return fmt.Errorf("failed to drop embedding cache collection: %w", err)
+	}
+
+	return nil
+}
+
+// Embed returns the cached embedding for the text, embedding & storing it on a miss
+func (c *embeddingCache) Embed(ctx context.Context, text string) ([]float32, error) {
+	key := c.key(text)
+	if embedding, found := c.get(key); found {
+		return embedding, nil
+	}
+
+	embedding, err := c.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.put(key, embedding)
+	if err != nil {
+		return nil, err
+	}
+
+	return embedding, nil
+}
+
+func (c *embeddingCache) Model() string {
+	return c.embedder.Model()
+}
+
+// key derives the cache key from the model id and text
+func (c *embeddingCache) key(text string) string {
+	return fmt.Sprintf("%x", xxhash.Sum64String(c.embedder.Model()+"\x00"+text))
+}
+
+// documentKey returns the cache key of a chunk document's embedding
+func (c *embeddingCache) documentKey(doc chromem.Document) string {
+	return c.key(embeddingText(doc))
+}
+
+// get reads a cached embedding, without embedding it on a miss
+func (c *embeddingCache) get(key string) ([]float32, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil || len(data) == 0 || len(data)%4 != 0 {
+		return nil, false
+	}
+
+	return decodeEmbedding(data), true
+}
+
+// put stores an embedding as little-endian float32s
+func (c *embeddingCache) put(key string, embedding []float32) error {
+	data := encodeEmbedding(embedding)
+
+	path := c.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to cache embedding: %w", err)
+	}
+
+	// Write & rename, so concurrent writers of the same text never leave a
+	// partial entry behind
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to cache embedding: %w", err)
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to cache embedding: %w", err)
+	}
+
+	return nil
+}
+
+// delete drops cached embeddings, unknown keys are ignored
+func (c *embeddingCache) delete(keys ...string) error {
+	for _, key := range keys {
+		err := os.Remove(c.path(key))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove cached embedding: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// keys lists the keys of every cached embedding
+func (c *embeddingCache) keys() ([]string, error) {
+	shards, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cached embeddings: %w", err)
+	}
+
+	var keys []string
+	for _, shard := range shards {
+		if !shard.IsDir() {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(c.dir, shard.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cached embeddings: %w", err)
+		}
+
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && filepath.Ext(entry.Name()) == "" {
+				keys = append(keys, entry.Name())
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+func (c *embeddingCache) path(key string) string {
+	return filepath.Join(c.dir, key[:min(2, len(key))], key)
+}
@@ -0,0 +1,154 @@
+package index
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+// cacheFixture indexes three files through a counting embedding provider &
+// closes the index
+func cacheFixture(t *testing.T, root string, cfg Config) []*parser.File {
+	files := []*parser.File{
+		writeFile(t, root, "a.go", "go", funcChunk("parseConfig", "return nil")),
+		writeFile(t, root, "b.go", "go", funcChunk("openStore", "return nil")),
+		writeFile(t, root, "c.go", "go", funcChunk("closeStore", "return nil")),
+	}
+
+	idx := openIndex(t, root, cfg)
+	indexFiles(t, idx, files...)
+	closeIndex(t, idx)
+
+	return files
+}
+
+func cachedKeys(t *testing.T, idx *Index) []string {
+	t.Helper()
+
+	keys, err := idx.embeddings.keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
+
+func TestEmbeddingCacheSurvivesMigrations(t *testing.T) {
+	tests := []struct {
+		name       string
+		reconfig   func(cfg *Config)
+		reembedded int64 // texts sent to the provider by the rescan
+		kept       bool  // whether the old entries survive the rescan
+	}{
+		{
+			name:     "unchanged",
+			reconfig: func(cfg *Config) {},
+			kept:     true,
+		},
+		{
+			name:     "quantization",
+			reconfig: func(cfg *Config) { cfg.Quantization.Method = QuantizationInt8 },
+			kept:     true,
+		},
+		{
+			name:     "parser",
+			reconfig: func(cfg *Config) { cfg.Parsers = map[string]string{"go": "v2"} },
+			kept:     true,
+		},
+		{
+			name:       "model",
+			reconfig:   func(cfg *Config) { cfg.Embedder.Model = "other-model" },
+			reembedded: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			embedder, provider := embeddingServer(t)
+			cfg := Config{Embedder: embedder, Parsers: map[string]string{"go": "v1"}}
+			files := cacheFixture(t, root, cfg)
+
+			test.reconfig(&cfg)
+			idx := openIndex(t, root, cfg)
+			defer closeIndex(t, idx)
+
+			before := cachedKeys(t, idx)
+			if len(before) != len(files) {
+				t.Fatalf("%d cached embeddings after reopening, expected %d", len(before), len(files))
+			}
+
+			// Pruning before the dropped files are re-indexed must keep them
+			err := idx.PruneEmbeddings()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(cachedKeys(t, idx), before) {
+				t.Fatal("pruning dropped the embeddings of files awaiting re-indexing")
+			}
+
+			provider.embedded.Store(0)
+			for _, file := range files {
+				if idx.IsStale(file.Path) {
+					indexFiles(t, idx, file)
+				}
+			}
+
+			if n := provider.embedded.Load(); n != test.reembedded {
+				t.Errorf("rescan embedded %d texts, expected %d", n, test.reembedded)
+			}
+
+			err = idx.PruneEmbeddings()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			after := cachedKeys(t, idx)
+			if len(after) != len(files) {
+				t.Errorf("%d cached embeddings after pruning, expected %d", len(after), len(files))
+			}
+			if slices.Equal(after, before) != test.kept {
+				t.Errorf("old cached embeddings kept: %v, expected %v", !test.kept, test.kept)
+			}
+		})
+	}
+}
+
+func TestEmbeddingCachePrunesRemovedChunks(t *testing.T) {
+	root := t.TempDir()
+	embedder, provider := embeddingServer(t)
+	cfg := Config{Embedder: embedder}
+	files := cacheFixture(t, root, cfg)
+
+	idx := openIndex(t, root, cfg)
+	defer closeIndex(t, idx)
+
+	// Re-indexing an unchanged file releases & references the same embedding
+	indexFiles(t, idx, files[2])
+
+	// Changing a chunk releases its old embedding
+	changed := writeFile(t, root, "b.go", "go", funcChunk("openStore", "return errClosed"))
+	indexFiles(t, idx, changed)
+
+	err := idx.Remove(context.Background(), files[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = idx.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only closeStore & the changed openStore are still referenced
+	if n := len(cachedKeys(t, idx)); n != 2 {
+		t.Errorf("%d cached embeddings after saving, expected 2", n)
+	}
+
+	if n := provider.embedded.Load(); n != 4 {
+		t.Errorf("embedded %d texts, expected 4", n)
+	}
+}
@@ -0,0 +1,94 @@
+package index
+
+import (
+	"context"
+	"math"
+)
+
+// diversify re-ranks results with maximal marginal relevance, trading each
+// result's fused relevance against its similarity to the results ranked above
+// it, and drops results from files that reached their cap. Without diversity
+// the ranking is kept & only the cap applies
+func (idx *Index) diversify(
+	ctx context.Context,
+	results []*SearchResult,
+	relevance []float64,
+	opts SearchOptions,
+) []*SearchResult {
+	if len(results) == 0 || opts.Diversity == 0 && opts.MaxPerFile == 0 {
+		return results
+	}
+
+	perFile := map[string]int{}
+	capped := func(result *SearchResult) bool {
+		return opts.MaxPerFile > 0 && perFile[result.File] >= opts.MaxPerFile
+	}
+	pick := func(ranked []*SearchResult, result *SearchResult) []*SearchResult {
+		perFile[result.File]++
+		return append(ranked, result)
+	}
+
+	ranked := make([]*SearchResult, 0, len(results))
+	if opts.Diversity == 0 {
+		for _, result := range results {
+			if !capped(result) {
+				ranked = pick(ranked, result)
+			}
+		}
+
+		return ranked
+	}
+
+	// Results without a stored embedding can't be compared, they count as
+	// unlike every other result
+	embeddings := make([][]float32, len(results))
+	for i, result := range results {
+		doc, err := idx.getDocument(ctx, result.ID)
+		if err == nil {
+			embeddings[i] = doc.Embedding
+		}
+	}
+
+	// Fused scores are scaled to the best one, so they weigh against cosine
+	// similarities on the same 0 to 1 scale
+	top := relevance[0]
+
+	// redundancy[i] is the highest similarity of result i to a picked result
+	redundancy := make([]float32, len(results))
+	picked := make([]bool, len(results))
+	lambda := 1 - opts.Diversity
+
+	// Greedy picks don't depend on how many follow, so stopping at the
+	// window keeps earlier pages stable
+	for len(ranked) < opts.window() {
+		best := -1
+		bestScore := float32(math.Inf(-1))
+		for i, result := range results {
+			if picked[i] || capped(result) {
+				continue
+			}
+
+			score := lambda*float32(relevance[i]/top) - opts.Diversity*redundancy[i]
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		if best < 0 {
+			return ranked
+		}
+
+		picked[best] = true
+		ranked = pick(ranked, results[best])
+
+		for i := range results {
+			if picked[i] || embeddings[i] == nil || embeddings[best] == nil {
+				continue
+			}
+
+			redundancy[i] = max(redundancy[i], cosineSimilarity(embeddings[i], embeddings[best]))
+		}
+	}
+
+	return ranked
+}
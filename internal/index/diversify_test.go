@@ -0,0 +1,118 @@
+package index
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestDiversify(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	idx := openIndex(t, root, offlineConfig())
+	defer closeIndex(t, idx)
+
+	indexFiles(t, idx,
+		writeFile(t, root, "open.go", "go",
+			funcChunk("openStore", "db, err := openDB(dir, readWrite)\n\treturn &Store{db: db}, err"),
+			funcChunk("openStoreReadOnly", "db, err := openDB(dir, readOnly)\n\treturn &Store{db: db}, err"),
+		),
+		writeFile(t, root, "close.go", "go", funcChunk("closeStore", "flushPending(s.queue)\n\treturn s.db.Close()")),
+	)
+
+	// Ranked by relevance, the two similar chunks first
+	ids := []string{"open.go::openStore", "open.go::openStoreReadOnly", "close.go::closeStore"}
+	relevance := []float64{0.05, 0.048, 0.046}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		ids  []string
+	}{
+		{
+			name: "relevance only",
+			opts: SearchOptions{},
+			ids:  ids,
+		},
+		{
+			name: "max per file",
+			opts: SearchOptions{MaxPerFile: 1},
+			ids:  []string{"open.go::openStore", "close.go::closeStore"},
+		},
+		{
+			name: "diversity",
+			opts: SearchOptions{Diversity: 0.5},
+			ids:  []string{"open.go::openStore", "close.go::closeStore", "open.go::openStoreReadOnly"},
+		},
+		{
+			name: "slight diversity keeps the ranking",
+			opts: SearchOptions{Diversity: 0.01},
+			ids:  ids,
+		},
+		{
+			name: "diversity & max per file",
+			opts: SearchOptions{Diversity: 0.5, MaxPerFile: 1},
+			ids:  []string{"open.go::openStore", "close.go::closeStore"},
+		},
+		{
+			name: "window",
+			opts: SearchOptions{Diversity: 0.5, Limit: 1},
+			ids:  []string{"open.go::openStore", "close.go::closeStore"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results := make([]*SearchResult, 0, len(ids))
+			for _, id := range ids {
+				chunk, err := idx.GetChunk(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				results = append(results, newSearchResult(chunk, 0))
+			}
+
+			ranked := []string{}
+			for _, result := range idx.diversify(ctx, results, relevance, test.opts.normalize(DefaultLimit)) {
+				ranked = append(ranked, result.ID)
+			}
+
+			if !slices.Equal(ranked, test.ids) {
+				t.Errorf("ranked %v, expected %v", ranked, test.ids)
+			}
+		})
+	}
+}
+
+func TestSearchMaxPerFile(t *testing.T) {
+	root := t.TempDir()
+	idx := openIndex(t, root, offlineConfig())
+	defer closeIndex(t, idx)
+
+	indexFiles(t, idx,
+		writeFile(t, root, "a.go", "go", funcChunk("openStore", "return nil"), funcChunk("openStoreReadOnly", "return nil")),
+		writeFile(t, root, "b.go", "go", funcChunk("openStoreCached", "return nil")),
+	)
+
+	for _, opts := range []SearchOptions{{MaxPerFile: 1}, {MaxPerFile: 1, Diversity: 0.3}} {
+		page, err := idx.Search(context.Background(), "open store", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := []string{}
+		for _, result := range page.Results {
+			files = append(files, result.File)
+		}
+		slices.Sort(files)
+
+		if !slices.Equal(files, []string{"a.go", "b.go"}) {
+			t.Errorf("options %+v found chunks of %v", opts, files)
+		}
+	}
+
+	_, err := idx.Search(context.Background(), "open store", SearchOptions{Diversity: 1.5})
+	if err == nil {
+		t.Error("a diversity above 1 was accepted")
+	}
+}
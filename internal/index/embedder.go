@@ -0,0 +1,129 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/philippgille/chromem-go"
+)
+
+const (
+	ProviderOpenAI       = "openai"
+	ProviderOpenAICompat = "openai-compat"
+	ProviderOllama       = "ollama"
+	ProviderOffline      = "offline"
+
+	defaultOpenAIModel   = string(chromem.EmbeddingModelOpenAI3Small)
+	defaultOllamaModel   = "nomic-embed-text"
+	defaultOllamaBaseURL = "http://localhost:11434/api"
+)
+
+// Embedder creates vector embeddings for chunk sources and search queries
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	Model() string // provider-qualified model id, e.g. "ollama:nomic-embed-text"
+}
+
+// EmbedderConfig selects and configures an embedding provider
+type EmbedderConfig struct {
+	Provider string // openai, openai-compat, ollama or offline
+	BaseURL  string // API base URL, required for openai-compat
+	APIKey   string // API key, if the provider needs one
+	Model    string // embedding model name
+}
+
+// EmbedderConfigFromEnv reads the embedding provider settings from the environment,
+// falling back to offline embeddings when no provider or OpenAI API key is set
+func EmbedderConfigFromEnv() EmbedderConfig {
+	apiKey := os.Getenv("CODE_SEARCH_EMBEDDING_API_KEY")
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+
+	provider := os.Getenv("CODE_SEARCH_EMBEDDER")
+	if provider == "" && apiKey != "" {
+		provider = ProviderOpenAI
+	} else if provider == "" {
+		provider = ProviderOffline
+	}
+
+	return EmbedderConfig{
+		Provider: provider,
+		BaseURL:  os.Getenv("CODE_SEARCH_EMBEDDING_BASE_URL"),
+		APIKey:   apiKey,
+		Model:    os.Getenv("CODE_SEARCH_EMBEDDING_MODEL"),
+	}
+}
+
+// NewEmbedder creates the embedder described by the config
+func NewEmbedder(cfg EmbedderConfig) (Embedder, error) {
+	switch cfg.Provider {
+	case ProviderOpenAI:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("%s embedder requires an API key", cfg.Provider)
+		}
+
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = chromem.BaseURLOpenAI
+		}
+
+		model := cfg.Model
+		if model == "" {
+			model = defaultOpenAIModel
+		}
+
+		// OpenAI embeddings are normalized
+		normalized := true
+		return &funcEmbedder{
+			model: cfg.Provider + ":" + model,
+			embed: chromem.NewEmbeddingFuncOpenAICompat(baseURL, cfg.APIKey, model, &normalized),
+		}, nil
+
+	case ProviderOpenAICompat:
+		if cfg.BaseURL == "" || cfg.Model == "" {
+			return nil, fmt.Errorf("%s embedder requires a base URL and a model", cfg.Provider)
+		}
+
+		return &funcEmbedder{
+			model: cfg.Provider + ":" + cfg.Model,
+			embed: chromem.NewEmbeddingFuncOpenAICompat(cfg.BaseURL, cfg.APIKey, cfg.Model, nil),
+		}, nil
+
+	case ProviderOllama:
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = defaultOllamaBaseURL
+		}
+
+		model := cfg.Model
+		if model == "" {
+			model = defaultOllamaModel
+		}
+
+		return &funcEmbedder{
+			model: cfg.Provider + ":" + model,
+			embed: chromem.NewEmbeddingFuncOllama(model, baseURL),
+		}, nil
+
+	case ProviderOffline:
+		return newLexicalEmbedder(), nil
+	}
+
+	return nil, fmt.Errorf("unknown embedding provider: %s", cfg.Provider)
+}
+
+// funcEmbedder adapts a chromem embedding function to the Embedder interface
+type funcEmbedder struct {
+	model string
+	embed chromem.EmbeddingFunc
+}
+
+func (e *funcEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return e.embed(ctx, text)
+}
+
+func (e *funcEmbedder) Model() string {
+	return e.model
+}
@@ -0,0 +1,146 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmbedderProviders(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       EmbedderConfig
+		basePath  string // appended to the test server URL as the base URL
+		path      string // expected request path
+		auth      string // expected Authorization header
+		model     string // expected model in the request
+		modelID   string // expected Model()
+		response  any
+		embedding []float32
+	}{
+		{
+			name:      "openai",
+			cfg:       EmbedderConfig{Provider: ProviderOpenAI, APIKey: "secret"},
+			path:      "/embeddings",
+			auth:      "Bearer secret",
+			model:     defaultOpenAIModel,
+			modelID:   "openai:" + defaultOpenAIModel,
+			response:  map[string]any{"data": []map[string]any{{"embedding": []float32{0.6, 0.8}}}},
+			embedding: []float32{0.6, 0.8},
+		},
+		{
+			name:      "openai-compat",
+			cfg:       EmbedderConfig{Provider: ProviderOpenAICompat, APIKey: "local", Model: "bge-small"},
+			basePath:  "/v1",
+			path:      "/v1/embeddings",
+			auth:      "Bearer local",
+			model:     "bge-small",
+			modelID:   "openai-compat:bge-small",
+			response:  map[string]any{"data": []map[string]any{{"embedding": []float32{3, 4}}}},
+			embedding: []float32{0.6, 0.8},
+		},
+		{
+			name:      "ollama",
+			cfg:       EmbedderConfig{Provider: ProviderOllama},
+			basePath:  "/api",
+			path:      "/api/embed",
+			model:     defaultOllamaModel,
+			modelID:   "ollama:" + defaultOllamaModel,
+			response:  map[string]any{"embeddings": [][]float32{{3, 4}}},
+			embedding: []float32{0.6, 0.8},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != test.path {
+					t.Errorf("request %s %s, expected POST %s", r.Method, r.URL.Path, test.path)
+				}
+
+				if auth := r.Header.Get("Authorization"); auth != test.auth {
+					t.Errorf("Authorization header %q, expected %q", auth, test.auth)
+				}
+
+				var body map[string]string
+				err := json.NewDecoder(r.Body).Decode(&body)
+				if err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+
+				if body["model"] != test.model || body["input"] != "func main() {}" {
+					t.Errorf("request body %v, expected model %s", body, test.model)
+				}
+
+				json.NewEncoder(w).Encode(test.response)
+			}))
+			defer server.Close()
+
+			cfg := test.cfg
+			cfg.BaseURL = server.URL + test.basePath
+
+			embedder, err := NewEmbedder(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if embedder.Model() != test.modelID {
+				t.Errorf("model %s, expected %s", embedder.Model(), test.modelID)
+			}
+
+			embedding, err := embedder.Embed(context.Background(), "func main() {}")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(embedding) != len(test.embedding) {
+				t.Fatalf("embedding %v, expected %v", embedding, test.embedding)
+			}
+			for i := range embedding {
+				if math.Abs(float64(embedding[i]-test.embedding[i])) > 1e-6 {
+					t.Fatalf("embedding %v, expected %v", embedding, test.embedding)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbedderErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	for _, cfg := range []EmbedderConfig{
+		{Provider: ProviderOpenAI, BaseURL: server.URL, APIKey: "secret"},
+		{Provider: ProviderOpenAICompat, BaseURL: server.URL, Model: "bge-small"},
+		{Provider: ProviderOllama, BaseURL: server.URL},
+	} {
+		embedder, err := NewEmbedder(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = embedder.Embed(context.Background(), "func main() {}")
+		if err == nil {
+			t.Errorf("%s embedder ignored an error response", cfg.Provider)
+		}
+	}
+}
+
+func TestEmbedderConfigErrors(t *testing.T) {
+	for _, cfg := range []EmbedderConfig{
+		{Provider: ProviderOpenAI},
+		{Provider: ProviderOpenAICompat, Model: "bge-small"},
+		{Provider: ProviderOpenAICompat, BaseURL: "http://localhost:8080/v1"},
+		{Provider: "unknown"},
+	} {
+		_, err := NewEmbedder(cfg)
+		if err == nil {
+			t.Errorf("config %+v was accepted", cfg)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package index
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+var goPackageClause = regexp.MustCompile(`(?m)^package\s+(\w+)`)
+
+// embeddingText prefixes a document's source with where it's defined, so a
+// method like Close is embedded knowing its file, package & type. Only the
+// embedding sees the prefix, the stored content stays the raw source
+func embeddingText(doc chromem.Document) string {
+	var header strings.Builder
+	header.WriteString("File: " + doc.Metadata["file"] + "\n")
+	header.WriteString("Language: " + doc.Metadata["language"] + "\n")
+
+	if pkg := doc.Metadata["package"]; pkg != "" {
+		header.WriteString("Package: " + pkg + "\n")
+	}
+
+	if isSymbol(parser.ChunkKind(doc.Metadata["kind"])) {
+		header.WriteString("Symbol: " + doc.Metadata["path"] + "\n")
+	}
+
+	if parentDoc := doc.Metadata["parentDoc"]; parentDoc != "" {
+		header.WriteString(parentDoc + "\n")
+	}
+
+	return header.String() + "\n" + doc.Content
+}
+
+// packageName returns the package or module a file belongs to, as its language
+// names it: the package clause in Go, the dotted module path in Python & the
+// import path in JavaScript & TypeScript
+func packageName(file *parser.File) string {
+	switch file.Language {
+	case "go":
+		match := goPackageClause.FindSubmatch(file.Source)
+		if match == nil {
+			return ""
+		}
+
+		return string(match[1])
+	case "python":
+		module := strings.TrimSuffix(file.Path, path.Ext(file.Path))
+		module = strings.TrimSuffix(module, "/__init__")
+		return strings.ReplaceAll(module, "/", ".")
+	case "javascript", "typescript":
+		return strings.TrimSuffix(file.Path, path.Ext(file.Path))
+	default:
+		return ""
+	}
+}
+
+// parentDoc returns the doc comment leading the chunk's parent, e.g. the type
+// of a method, or an empty string if it has none
+func parentDoc(file *parser.File, chunk *parser.Chunk) string {
+	parentPath, _, found := cutLast(chunk.Path, "::")
+	if !found {
+		return ""
+	}
+
+	for _, parent := range file.Chunks {
+		if parent.Path == parentPath {
+			return leadingComment(parent.Source)
+		}
+	}
+
+	return ""
+}
+
+// leadingComment returns the comment lines a chunk's source starts with
+func leadingComment(source string) string {
+	var lines []string
+	for _, line := range strings.Split(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !isCommentLine(trimmed) {
+			break
+		}
+
+		lines = append(lines, trimmed)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func isCommentLine(line string) bool {
+	for _, marker := range []string{"//", "#", "/*", "*"} {
+		if strings.HasPrefix(line, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,123 @@
+package index
+
+import (
+	"context"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+func TestEmbeddingText(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		text     string
+	}{
+		{
+			name: "method",
+			metadata: map[string]string{
+				"file":      "internal/index/store.go",
+				"language":  "go",
+				"package":   "index",
+				"kind":      string(parser.KindMethod),
+				"path":      "Store::Close",
+				"parentDoc": "// Store persists chunks",
+			},
+			text: "File: internal/index/store.go\nLanguage: go\nPackage: index\nSymbol: Store::Close\n// Store persists chunks\n\nfunc (s *Store) Close() error",
+		},
+		{
+			name: "content-hashed chunk",
+			metadata: map[string]string{
+				"file":     "README.md",
+				"language": "markdown",
+				"kind":     string(parser.KindOther),
+				"path":     "a1b2c3",
+			},
+			text: "File: README.md\nLanguage: markdown\n\nfunc (s *Store) Close() error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text := embeddingText(chromem.Document{Metadata: test.metadata, Content: "func (s *Store) Close() error"})
+			if text != test.text {
+				t.Errorf("embedding text %q, expected %q", text, test.text)
+			}
+		})
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	tests := []struct {
+		file parser.File
+		pkg  string
+	}{
+		{parser.File{Path: "internal/index/store.go", Language: "go", Source: []byte("// Package index\npackage index\n")}, "index"},
+		{parser.File{Path: "main.go", Language: "go", Source: []byte("func main() {}")}, ""},
+		{parser.File{Path: "app/models/user.py", Language: "python"}, "app.models.user"},
+		{parser.File{Path: "app/models/__init__.py", Language: "python"}, "app.models"},
+		{parser.File{Path: "src/components/Button.tsx", Language: "typescript"}, "src/components/Button"},
+		{parser.File{Path: "README.md", Language: "markdown"}, ""},
+	}
+
+	for _, test := range tests {
+		if pkg := packageName(&test.file); pkg != test.pkg {
+			t.Errorf("package of %s is %q, expected %q", test.file.Path, pkg, test.pkg)
+		}
+	}
+}
+
+func TestParentDoc(t *testing.T) {
+	file := &parser.File{Chunks: []*parser.Chunk{
+		{Path: "Store", Source: "// Store persists chunks\n// in a vector db\ntype Store struct {}"},
+		{Path: "Store::Close", Source: "func (s *Store) Close() error {}"},
+		{Path: "Index", Source: "type Index struct {}"},
+		{Path: "Index::Close", Source: "func (idx *Index) Close() error {}"},
+		{Path: "Loader::load", Source: "def load(self):"},
+	}}
+
+	tests := []struct {
+		path string
+		doc  string
+	}{
+		{"Store::Close", "// Store persists chunks\n// in a vector db"},
+		{"Index::Close", ""},
+		{"Loader::load", ""},
+		{"Store", ""},
+	}
+
+	for _, test := range tests {
+		chunk := &parser.Chunk{Path: test.path}
+		if doc := parentDoc(file, chunk); doc != test.doc {
+			t.Errorf("parent doc of %s is %q, expected %q", test.path, doc, test.doc)
+		}
+	}
+}
+
+func TestIndexEmbedsContext(t *testing.T) {
+	root := t.TempDir()
+	idx := openIndex(t, root, offlineConfig())
+	defer closeIndex(t, idx)
+
+	indexFiles(t, idx, writeFile(t, root, "store.go", "go",
+		testChunk{path: "a1b2c3", kind: parser.KindOther, source: "package index"},
+		testChunk{path: "Store", kind: parser.KindType, source: "// Store persists chunks\ntype Store struct{}"},
+		testChunk{path: "Store::Close", kind: parser.KindMethod, source: "func (s *Store) Close() error {\n\treturn nil\n}"},
+	))
+
+	doc, err := idx.getDocument(context.Background(), "store.go::Store::Close")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Metadata["package"] != "index" || doc.Metadata["parentDoc"] != "// Store persists chunks" {
+		t.Errorf("stored metadata %v", doc.Metadata)
+	}
+
+	// The stored content is the raw source, only the embedding sees the header
+	expected, _ := idx.embedder.Embed(context.Background(), embeddingText(doc))
+	if doc.Content != "func (s *Store) Close() error {\n\treturn nil\n}" || cosineSimilarity(doc.Embedding, expected) < 0.9999 {
+		t.Errorf("stored %q, embedded without its header", doc.Content)
+	}
+}
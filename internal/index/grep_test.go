@@ -0,0 +1,106 @@
+package index
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+func TestGrepChunks(t *testing.T) {
+	root := t.TempDir()
+	idx := openIndex(t, root, offlineConfig())
+	defer closeIndex(t, idx)
+
+	indexFiles(t, idx,
+		writeFile(t, root, "store.go", "go",
+			funcChunk("openStore", "return sql.Open(dir)"),
+			funcChunk("closeStore", "return s.Close()"),
+		),
+		writeFile(t, root, "store_test.go", "go", funcChunk("TestOpenStore", "openStore(t.TempDir())")),
+		writeFile(t, root, "README.md", "markdown", testChunk{
+			path:   "usage",
+			kind:   parser.KindOther,
+			source: "# Usage\nCall openStore, then closeStore.",
+		}),
+	)
+
+	tests := []struct {
+		name       string
+		pattern    string
+		literal    bool
+		ignoreCase bool
+		fileTypes  []string
+		matches    []string
+	}{
+		{
+			name:    "regex across chunks, sorted by file & line",
+			pattern: `return \w+\.(Open|Close)`,
+			matches: []string{
+				"store.go::openStore | func openStore() { [line 2]",
+				"store.go::closeStore | func closeStore() { [line 5]",
+			},
+		},
+		{
+			name:    "several lines of a chunk",
+			pattern: `Store`,
+			matches: []string{
+				"README.md::usage | # Usage [line 2]",
+				"store.go::openStore | func openStore() { [line 1]",
+				"store.go::closeStore | func closeStore() { [line 4]",
+			},
+		},
+		{
+			name:    "literal",
+			pattern: "Open(dir)",
+			literal: true,
+			matches: []string{"store.go::openStore | func openStore() { [line 2]"},
+		},
+		{
+			name:    "regex metacharacters aren't literal",
+			pattern: "Open(dir)",
+			matches: []string{},
+		},
+		{
+			name:       "ignore case",
+			pattern:    "usage",
+			ignoreCase: true,
+			matches:    []string{"README.md::usage | # Usage [line 1]"},
+		},
+		{
+			name:      "file types",
+			pattern:   "openStore",
+			fileTypes: []string{"tests"},
+			matches:   []string{"store_test.go::TestOpenStore | func TestOpenStore() { [line 2]"},
+		},
+		{
+			name:    "chunk lines",
+			pattern: "^func|^\treturn",
+			matches: []string{
+				"store.go::openStore | func openStore() { [lines 1, 2]",
+				"store.go::closeStore | func closeStore() { [lines 4, 5]",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matches, err := idx.GrepChunks(context.Background(), test.pattern, test.literal, test.ignoreCase, test.fileTypes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(matches, test.matches) {
+				t.Errorf("matched %q, expected %q", matches, test.matches)
+			}
+		})
+	}
+
+	for _, pattern := range []string{"", "(unclosed"} {
+		_, err := idx.GrepChunks(context.Background(), pattern, false, false, nil)
+		if err == nil {
+			t.Errorf("pattern %q was accepted", pattern)
+		}
+	}
+}
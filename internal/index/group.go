@@ -0,0 +1,93 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GroupByFile is the semantic_search group_by mode listing files instead of chunks
+const GroupByFile = "file"
+
+// maxFileChunks is the number of top chunks listed for each file
+const maxFileChunks = 3
+
+// FileResult is a file matched by a search, with its best matching chunks
+type FileResult struct {
+	File     string          `json:"file"`
+	Repo     string          `json:"repo,omitempty"` // alias of the repository, when searching several
+	Language string          `json:"language"`
+	Score    float32         `json:"score"`   // cosine similarity of the best matching chunk
+	Matches  int             `json:"matches"` // number of matching chunks
+	Chunks   []*SearchResult `json:"chunks"`  // top ranked chunks, best first
+}
+
+// FilePage is a page of search results grouped by file
+type FilePage struct {
+	Files      []*FileResult `json:"files"`
+	NextOffset int           `json:"next_offset,omitempty"` // 0 when there are no more files
+}
+
+// String summarizes the file on one line, followed by a line per top chunk
+func (r *FileResult) String() string {
+	file := r.File
+	if r.Repo != "" {
+		file = r.Repo + ":" + r.File
+	}
+
+	lines := []string{fmt.Sprintf("%s [%s] score %.2f, %d matching chunks", file, r.Language, r.Score, r.Matches)}
+	for _, chunk := range r.Chunks {
+		lines = append(lines, "    "+chunk.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// GroupFiles aggregates ranked chunk results by file & pages the files. Files
+// are ranked by the summed reciprocal ranks of their top chunks, so a file with
+// several strong matches outranks one with a single slightly better match, but
+// not a large file with many weak ones
+func GroupFiles(results []*SearchResult, opts SearchOptions) *FilePage {
+	opts = opts.normalize(DefaultLimit)
+
+	scores := map[string]float64{}
+	files := map[string]*FileResult{}
+	var order []string
+
+	for rank, result := range results {
+		key := result.Repo + ":" + result.File
+		file, seen := files[key]
+		if !seen {
+			file = &FileResult{File: result.File, Repo: result.Repo, Language: result.Language}
+			files[key] = file
+			order = append(order, key)
+		}
+
+		file.Score = max(file.Score, result.Score)
+		file.Matches++
+		if len(file.Chunks) < maxFileChunks {
+			file.Chunks = append(file.Chunks, result)
+			scores[key] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	// Stable, so ties keep the order of the files' best chunks
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	page := &FilePage{Files: []*FileResult{}}
+	if opts.Offset >= len(order) {
+		return page
+	}
+
+	end := min(opts.Offset+opts.Limit, len(order))
+	for _, key := range order[opts.Offset:end] {
+		page.Files = append(page.Files, files[key])
+	}
+	if end < len(order) {
+		page.NextOffset = end
+	}
+
+	return page
+}
@@ -0,0 +1,83 @@
+package index
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGroupFiles(t *testing.T) {
+	result := func(repo, file, path string, score float32) *SearchResult {
+		return &SearchResult{ID: file + "::" + path, Repo: repo, File: file, Language: "go", Path: path, Score: score}
+	}
+
+	// Ranked chunks: b.go has the best match, a.go several strong ones
+	results := []*SearchResult{
+		result("", "b.go", "Best", 0.9),
+		result("", "a.go", "First", 0.8),
+		result("", "a.go", "Second", 0.7),
+		result("", "c.go", "Only", 0.6),
+		result("", "a.go", "Third", 0.5),
+		result("", "a.go", "Fourth", 0.4),
+		result("web", "b.go", "Other", 0.3),
+	}
+
+	tests := []struct {
+		name       string
+		opts       SearchOptions
+		files      []string // repo:file
+		nextOffset int
+	}{
+		{
+			name:  "ranked by summed reciprocal ranks",
+			opts:  SearchOptions{},
+			files: []string{":a.go", ":b.go", ":c.go", "web:b.go"},
+		},
+		{
+			name:       "first page",
+			opts:       SearchOptions{Limit: 2},
+			files:      []string{":a.go", ":b.go"},
+			nextOffset: 2,
+		},
+		{
+			name:  "last page",
+			opts:  SearchOptions{Limit: 2, Offset: 2},
+			files: []string{":c.go", "web:b.go"},
+		},
+		{
+			name:  "past the end",
+			opts:  SearchOptions{Offset: 10},
+			files: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := GroupFiles(results, test.opts)
+
+			files := []string{}
+			for _, file := range page.Files {
+				files = append(files, file.Repo+":"+file.File)
+			}
+
+			if !slices.Equal(files, test.files) || page.NextOffset != test.nextOffset {
+				t.Errorf("grouped %v next %d, expected %v next %d", files, page.NextOffset, test.files, test.nextOffset)
+			}
+		})
+	}
+
+	a := GroupFiles(results, SearchOptions{}).Files[0]
+	if a.Score != 0.8 || a.Matches != 4 || len(a.Chunks) != maxFileChunks || a.Chunks[2].Path != "Third" {
+		t.Errorf("grouped a.go as %+v", a)
+	}
+
+	lines := strings.Split(a.String(), "\n")
+	if len(lines) != 1+maxFileChunks || lines[0] != "a.go [go] score 0.80, 4 matching chunks" {
+		t.Errorf("a.go renders as:\n%s", a.String())
+	}
+
+	web := GroupFiles(results, SearchOptions{}).Files[3]
+	if !strings.HasPrefix(web.String(), "web:b.go [go]") {
+		t.Errorf("web:b.go renders as:\n%s", web.String())
+	}
+}
@@ -0,0 +1,677 @@
+package index
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/philippgille/chromem-go"
+)
+
+const (
+	// ANNExact searches every vector, ANNHNSW searches an HNSW graph
+	ANNExact = "exact"
+	ANNHNSW  = "hnsw"
+
+	defaultHNSWM              = 16
+	defaultHNSWEfConstruction = 200
+	defaultHNSWEfSearch       = 64
+
+	// Recall is measured on this many stored chunks, as recall@recallK
+	recallSamples = 100
+	recallK       = 10
+
+	// graphFilePrefix names the persisted graph of each collection, next to the vector db
+	graphFilePrefix = "hnsw-"
+)
+
+// ANNConfig selects how similarity searches find nearest neighbours
+type ANNConfig struct {
+	Method   string // exact or hnsw
+	EfSearch int    // size of the HNSW candidate list, higher is slower but more accurate
+}
+
+// ANNConfigFromEnv reads the nearest neighbour search settings from the environment
+func ANNConfigFromEnv() ANNConfig {
+	efSearch, _ := strconv.Atoi(os.Getenv("CODE_SEARCH_ANN_EF_SEARCH"))
+
+	return ANNConfig{
+		Method:   os.Getenv("CODE_SEARCH_ANN"),
+		EfSearch: efSearch,
+	}
+}
+
+// hnswGraph is a Hierarchical Navigable Small World graph over normalized
+// vectors, finding approximate nearest neighbours in logarithmic time instead
+// of comparing the query to every vector
+type hnswGraph struct {
+	m              int // max links per node on upper levels
+	mMax0          int // max links per node on level 0
+	efConstruction int
+	efSearch       int
+	levelMult      float64
+
+	nodes map[string]*hnswNode
+	entry *hnswNode
+	rng   *rand.Rand
+
+	ready   bool            // every stored chunk was inserted, until then searches are exact
+	changed map[string]bool // IDs written while building, the build skips them
+	dirty   bool            // changed since it was last saved
+	recall  float64         // measured recall@recallK against exact search
+	mu      sync.RWMutex
+}
+
+type hnswNode struct {
+	id      string
+	vector  []float32
+	friends [][]string // linked node IDs per level, level 0 first
+}
+
+// hnswCandidate is a node with its similarity to the vector being searched for
+type hnswCandidate struct {
+	node       *hnswNode
+	similarity float32
+}
+
+func newHNSWGraph(efSearch int) *hnswGraph {
+	if efSearch <= 0 {
+		efSearch = defaultHNSWEfSearch
+	}
+
+	return &hnswGraph{
+		m:              defaultHNSWM,
+		mMax0:          2 * defaultHNSWM,
+		efConstruction: defaultHNSWEfConstruction,
+		efSearch:       efSearch,
+		levelMult:      1 / math.Log(defaultHNSWM),
+		nodes:          map[string]*hnswNode{},
+		rng:            rand.New(rand.NewSource(1)),
+	}
+}
+
+// insert adds a vector to the graph, replacing any previous one with the same ID
+func (g *hnswGraph) insert(id string, vector []float32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.changed != nil {
+		g.changed[id] = true
+	}
+	g.insertLocked(id, vector)
+}
+
+func (g *hnswGraph) insertLocked(id string, vector []float32) {
+	g.removeLocked(id)
+	g.dirty = true
+
+	level := int(-math.Log(1-g.rng.Float64()) * g.levelMult)
+	node := &hnswNode{id: id, vector: normalized(vector), friends: make([][]string, level+1)}
+	g.nodes[id] = node
+
+	if g.entry == nil {
+		g.entry = node
+		return
+	}
+
+	entry := g.entry
+	topLevel := len(entry.friends) - 1
+	nearest := hnswCandidate{entry, dot(node.vector, entry.vector)}
+	for l := topLevel; l > level; l-- {
+		nearest = g.greedySearch(node.vector, nearest, l)
+	}
+
+	entryPoints := []hnswCandidate{nearest}
+	for l := min(level, topLevel); l >= 0; l-- {
+		found := g.searchLayer(node.vector, entryPoints, g.efConstruction, l)
+		neighbours := g.selectNeighbours(found, g.m)
+
+		maxFriends := g.m
+		if l == 0 {
+			maxFriends = g.mMax0
+		}
+
+		for _, neighbour := range neighbours {
+			node.friends[l] = append(node.friends[l], neighbour.node.id)
+			neighbour.node.friends[l] = append(neighbour.node.friends[l], id)
+			if len(neighbour.node.friends[l]) > maxFriends {
+				g.prune(neighbour.node, l, maxFriends)
+			}
+		}
+
+		entryPoints = found
+	}
+
+	if level > topLevel {
+		g.entry = node
+	}
+}
+
+// remove drops vectors from the graph
+func (g *hnswGraph) remove(ids ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, id := range ids {
+		if g.changed != nil {
+			g.changed[id] = true
+		}
+		g.removeLocked(id)
+	}
+}
+
+// trackWrites records the IDs inserted or removed from now on, so a build from
+// a snapshot of the stored vectors taken before doesn't overwrite them
+func (g *hnswGraph) trackWrites() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.changed = map[string]bool{}
+}
+
+// build inserts a snapshot of the stored vectors, skipping IDs written since
+// writes were tracked, and marks the graph ready
+func (g *hnswGraph) build(vectors map[string][]float32) {
+	for id, vector := range vectors {
+		g.mu.Lock()
+		if !g.changed[id] {
+			g.insertLocked(id, vector)
+		}
+		g.mu.Unlock()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.changed = nil
+	g.ready = true
+}
+
+// removeLocked unlinks a node and reconnects its neighbours among each other,
+// so the graph stays navigable
+func (g *hnswGraph) removeLocked(id string) {
+	node, exists := g.nodes[id]
+	if !exists {
+		return
+	}
+
+	delete(g.nodes, id)
+	g.dirty = true
+
+	for l, friends := range node.friends {
+		maxFriends := g.m
+		if l == 0 {
+			maxFriends = g.mMax0
+		}
+
+		for _, friendID := range friends {
+			friend, exists := g.nodes[friendID]
+			if !exists || l >= len(friend.friends) {
+				continue
+			}
+
+			links := slices.DeleteFunc(friend.friends[l], func(linked string) bool { return linked == id })
+			for _, candidate := range friends {
+				if candidate != friendID && !slices.Contains(links, candidate) {
+					links = append(links, candidate)
+				}
+			}
+			friend.friends[l] = links
+
+			if len(links) > maxFriends {
+				g.prune(friend, l, maxFriends)
+			}
+		}
+	}
+
+	if g.entry == node {
+		g.entry = nil
+		for _, candidate := range g.nodes {
+			if g.entry == nil || len(candidate.friends) > len(g.entry.friends) {
+				g.entry = candidate
+			}
+		}
+	}
+}
+
+// search returns the approximate k nearest neighbours of the query
+func (g *hnswGraph) search(query []float32, k int) []chromem.Result {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if g.entry == nil || k <= 0 {
+		return nil
+	}
+
+	query = normalized(query)
+	nearest := hnswCandidate{g.entry, dot(query, g.entry.vector)}
+	for l := len(g.entry.friends) - 1; l > 0; l-- {
+		nearest = g.greedySearch(query, nearest, l)
+	}
+
+	found := g.searchLayer(query, []hnswCandidate{nearest}, max(g.efSearch, k), 0)
+	if len(found) > k {
+		found = found[:k]
+	}
+
+	results := make([]chromem.Result, 0, len(found))
+	for _, candidate := range found {
+		results = append(results, chromem.Result{ID: candidate.node.id, Similarity: candidate.similarity})
+	}
+
+	return results
+}
+
+// greedySearch walks a level towards the node closest to the vector
+func (g *hnswGraph) greedySearch(vector []float32, nearest hnswCandidate, level int) hnswCandidate {
+	for improved := true; improved; {
+		improved = false
+		for _, friendID := range nearest.node.friends[level] {
+			friend, exists := g.nodes[friendID]
+			if !exists {
+				continue
+			}
+
+			similarity := dot(vector, friend.vector)
+			if similarity > nearest.similarity {
+				nearest = hnswCandidate{friend, similarity}
+				improved = true
+			}
+		}
+	}
+
+	return nearest
+}
+
+// searchLayer finds up to ef nodes closest to the vector on a level, most
+// similar first
+func (g *hnswGraph) searchLayer(vector []float32, entryPoints []hnswCandidate, ef int, level int) []hnswCandidate {
+	visited := map[string]bool{}
+	candidates := &candidateHeap{}     // closest first
+	found := &candidateHeap{min: true} // furthest first
+	for _, entryPoint := range entryPoints {
+		visited[entryPoint.node.id] = true
+		heap.Push(candidates, entryPoint)
+		heap.Push(found, entryPoint)
+		if found.Len() > ef {
+			heap.Pop(found)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		candidate := heap.Pop(candidates).(hnswCandidate)
+		if found.Len() >= ef && candidate.similarity < found.items[0].similarity {
+			break
+		}
+
+		if level >= len(candidate.node.friends) {
+			continue
+		}
+
+		for _, friendID := range candidate.node.friends[level] {
+			if visited[friendID] {
+				continue
+			}
+			visited[friendID] = true
+
+			friend, exists := g.nodes[friendID]
+			if !exists {
+				continue
+			}
+
+			similarity := dot(vector, friend.vector)
+			if found.Len() < ef || similarity > found.items[0].similarity {
+				heap.Push(candidates, hnswCandidate{friend, similarity})
+				heap.Push(found, hnswCandidate{friend, similarity})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	results := found.items
+	slices.SortFunc(results, bySimilarity)
+
+	return results
+}
+
+// selectNeighbours picks up to m of the candidates, most similar first, skipping
+// candidates closer to an already picked one than to the base vector, so links
+// spread in all directions instead of into a single cluster
+func (g *hnswGraph) selectNeighbours(candidates []hnswCandidate, m int) []hnswCandidate {
+	selected := make([]hnswCandidate, 0, m)
+	var skipped []hnswCandidate
+	for _, candidate := range candidates {
+		if len(selected) >= m {
+			break
+		}
+
+		diverse := true
+		for _, picked := range selected {
+			if dot(candidate.node.vector, picked.node.vector) > candidate.similarity {
+				diverse = false
+				break
+			}
+		}
+
+		if diverse {
+			selected = append(selected, candidate)
+		} else {
+			skipped = append(skipped, candidate)
+		}
+	}
+
+	// Fill up with the closest skipped candidates, to keep enough links
+	for _, candidate := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, candidate)
+	}
+
+	return selected
+}
+
+// prune trims the links of a node on a level down to maxFriends
+func (g *hnswGraph) prune(node *hnswNode, level int, maxFriends int) {
+	candidates := make([]hnswCandidate, 0, len(node.friends[level]))
+	for _, friendID := range node.friends[level] {
+		friend, exists := g.nodes[friendID]
+		if exists {
+			candidates = append(candidates, hnswCandidate{friend, dot(node.vector, friend.vector)})
+		}
+	}
+
+	slices.SortFunc(candidates, bySimilarity)
+
+	kept := g.selectNeighbours(candidates, maxFriends)
+	node.friends[level] = node.friends[level][:0]
+	for _, candidate := range kept {
+		node.friends[level] = append(node.friends[level], candidate.node.id)
+	}
+}
+
+// measureRecall compares searches for sampled stored vectors to exact search,
+// returning the fraction of the exact top recallK results the graph found.
+// Sampled vectors are left out of both results, finding themselves would
+// inflate the recall.
+func (g *hnswGraph) measureRecall() float64 {
+	g.mu.RLock()
+	nodes := make([]*hnswNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, node)
+	}
+	g.mu.RUnlock()
+
+	if len(nodes) <= recallK {
+		return 1
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	var hits, total int
+	for range min(recallSamples, len(nodes)) {
+		query := nodes[rng.Intn(len(nodes))]
+
+		exact := make([]hnswCandidate, 0, len(nodes))
+		for _, node := range nodes {
+			if node != query {
+				exact = append(exact, hnswCandidate{node, dot(query.vector, node.vector)})
+			}
+		}
+		slices.SortFunc(exact, bySimilarity)
+
+		approximate := map[string]bool{}
+		for _, result := range g.search(query.vector, recallK+1) {
+			if result.ID != query.id && len(approximate) < recallK {
+				approximate[result.ID] = true
+			}
+		}
+
+		for _, candidate := range exact[:recallK] {
+			if approximate[candidate.node.id] {
+				hits++
+			}
+		}
+		total += recallK
+	}
+
+	recall := float64(hits) / float64(total)
+
+	g.mu.Lock()
+	g.recall = recall
+	g.mu.Unlock()
+
+	return recall
+}
+
+// hnswFile is the persisted graph structure, vectors are restored from the db
+type hnswFile struct {
+	Entry string
+	Nodes map[string][][]string // node ID -> linked node IDs per level
+}
+
+// save writes the graph structure to a file
+func (g *hnswGraph) save(path string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.ready || !g.dirty {
+		return nil
+	}
+
+	data := hnswFile{Nodes: make(map[string][][]string, len(g.nodes))}
+	if g.entry != nil {
+		data.Entry = g.entry.id
+	}
+	for id, node := range g.nodes {
+		data.Nodes[id] = node.friends
+	}
+
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	err = gob.NewEncoder(file).Encode(data)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to encode HNSW graph: %w", err)
+	}
+
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	g.dirty = false
+	return nil
+}
+
+// load restores the graph structure from a file, it must cover exactly the
+// given vectors, otherwise the graph is out of date
+func (g *hnswGraph) load(path string, vectors map[string][]float32) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var data hnswFile
+	err = gob.NewDecoder(file).Decode(&data)
+	if err != nil {
+		return fmt.Errorf("failed to decode HNSW graph: %w", err)
+	}
+
+	if len(data.Nodes) != len(vectors) {
+		return fmt.Errorf("HNSW graph has %d nodes, expected %d", len(data.Nodes), len(vectors))
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	nodes := make(map[string]*hnswNode, len(data.Nodes))
+	for id, friends := range data.Nodes {
+		vector, exists := vectors[id]
+		if !exists {
+			return fmt.Errorf("HNSW graph is out of date")
+		}
+		nodes[id] = &hnswNode{id: id, vector: normalized(vector), friends: friends}
+	}
+
+	g.nodes = nodes
+	g.entry = nodes[data.Entry]
+	g.ready = true
+	return nil
+}
+
+func (g *hnswGraph) isReady() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.ready
+}
+
+// bySimilarity orders candidates most similar first
+func bySimilarity(a, b hnswCandidate) int {
+	return cmp.Compare(b.similarity, a.similarity)
+}
+
+// candidateHeap orders candidates by similarity, highest first unless min is set
+type candidateHeap struct {
+	items []hnswCandidate
+	min   bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.min {
+		return h.items[i].similarity < h.items[j].similarity
+	}
+	return h.items[i].similarity > h.items[j].similarity
+}
+
+func (h *candidateHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *candidateHeap) Push(x any) { h.items = append(h.items, x.(hnswCandidate)) }
+
+func (h *candidateHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// normalized returns the vector scaled to unit length, so dot products are
+// cosine similarities
+func normalized(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+
+	if norm == 0 || math.Abs(norm-1) < 1e-6 {
+		return vector
+	}
+
+	scale := float32(1 / math.Sqrt(norm))
+	result := make([]float32, len(vector))
+	for i, v := range vector {
+		result[i] = v * scale
+	}
+
+	return result
+}
+
+func dot(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+
+	return sum
+}
+
+// openGraphs loads the persisted HNSW graph of each collection, building the
+// graphs that are missing or out of date in the background. Until a graph is
+// ready, searches of its collection are exact.
+func (idx *Index) openGraphs(ctx context.Context, efSearch int) {
+	for fileType, store := range idx.stores {
+		graph := newHNSWGraph(efSearch)
+		idx.graphs[fileType] = graph
+
+		docs, err := store.List(ctx, true)
+		if err != nil {
+			log.Printf("Failed to load %s HNSW graph: %v", fileType, err)
+			continue
+		}
+
+		vectors := make(map[string][]float32, len(docs))
+		for _, doc := range docs {
+			vectors[doc.ID] = doc.Embedding
+		}
+
+		err = graph.load(idx.graphPath(fileType), vectors)
+		if err == nil {
+			go idx.logRecall(fileType, graph)
+			continue
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Rebuilding %s HNSW graph: %v", fileType, err)
+		}
+
+		// Chunks may be indexed or removed while the graph is built
+		graph.trackWrites()
+		go func() {
+			graph.build(vectors)
+			idx.logRecall(fileType, graph)
+		}()
+	}
+}
+
+// logRecall benchmarks a graph against exact search, small graphs are skipped
+// as their searches are exhaustive anyway
+func (idx *Index) logRecall(fileType string, graph *hnswGraph) {
+	graph.mu.RLock()
+	size := len(graph.nodes)
+	graph.mu.RUnlock()
+
+	if size <= recallK {
+		return
+	}
+
+	recall := graph.measureRecall()
+	log.Printf("HNSW recall@%d for %s chunks: %.3f", recallK, fileType, recall)
+}
+
+func (idx *Index) graphPath(fileType string) string {
+	return filepath.Join(idx.dbPath, graphFilePrefix+fileType+".gob")
+}
+
+// saveGraphs persists the HNSW graphs that changed
+func (idx *Index) saveGraphs() error {
+	for fileType, graph := range idx.graphs {
+		err := graph.save(idx.graphPath(fileType))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
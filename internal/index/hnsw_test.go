@@ -0,0 +1,130 @@
+package index
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// clusteredVectors returns random vectors scattered around a few centroids,
+// closer to how embeddings of related code are distributed than uniform noise
+func clusteredVectors(rng *rand.Rand, centroids [][]float32, n int) [][]float32 {
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		centroid := centroids[rng.Intn(len(centroids))]
+		vector := make([]float32, len(centroid))
+		for j := range vector {
+			vector[j] = centroid[j] + float32(rng.NormFloat64())*2
+		}
+		vectors[i] = normalized(vector)
+	}
+
+	return vectors
+}
+
+// hnswFixture builds a graph of n stored vectors & draws held-out queries from
+// the same distribution, which are never inserted
+func hnswFixture(n, dims, nQueries int) (*hnswGraph, map[string][]float32, [][]float32) {
+	rng := rand.New(rand.NewSource(1))
+	centroids := make([][]float32, 32)
+	for i := range centroids {
+		centroids[i] = make([]float32, dims)
+		for j := range centroids[i] {
+			centroids[i][j] = float32(rng.NormFloat64())
+		}
+	}
+
+	vectors := map[string][]float32{}
+	for i, vector := range clusteredVectors(rng, centroids, n) {
+		vectors[fmt.Sprintf("chunk-%d", i)] = vector
+	}
+
+	graph := newHNSWGraph(0)
+	graph.trackWrites()
+	graph.build(vectors)
+
+	return graph, vectors, clusteredVectors(rng, centroids, nQueries)
+}
+
+// heldOutRecall returns the fraction of the exact top recallK neighbours of
+// each query the graph finds
+func heldOutRecall(graph *hnswGraph, vectors map[string][]float32, queries [][]float32) float64 {
+	var hits, total int
+	for _, query := range queries {
+		exact := make([]string, 0, len(vectors))
+		for id := range vectors {
+			exact = append(exact, id)
+		}
+		slices.SortFunc(exact, func(a, b string) int {
+			return cmp.Compare(dot(query, vectors[b]), dot(query, vectors[a]))
+		})
+
+		found := map[string]bool{}
+		for _, result := range graph.search(query, recallK) {
+			found[result.ID] = true
+		}
+
+		for _, id := range exact[:recallK] {
+			if found[id] {
+				hits++
+			}
+		}
+		total += recallK
+	}
+
+	return float64(hits) / float64(total)
+}
+
+func TestHNSWRecallOnHeldOutQueries(t *testing.T) {
+	graph, vectors, queries := hnswFixture(2000, 64, 100)
+
+	recall := heldOutRecall(graph, vectors, queries)
+	if recall < 0.9 {
+		t.Errorf("recall@%d on held-out queries is %.3f, expected at least 0.9", recallK, recall)
+	}
+}
+
+func TestHNSWBuildSkipsWritesSinceSnapshot(t *testing.T) {
+	snapshot := map[string][]float32{
+		"removed":  {1, 0, 0},
+		"replaced": {0, 1, 0},
+		"kept":     {0, 0, 1},
+	}
+
+	graph := newHNSWGraph(0)
+	graph.trackWrites()
+
+	// Written after the snapshot was taken, but before the build reached them
+	graph.remove("removed")
+	graph.insert("replaced", []float32{0, 0, -1})
+
+	graph.build(snapshot)
+
+	if _, exists := graph.nodes["removed"]; exists {
+		t.Error("removed vector was restored from the snapshot")
+	}
+
+	if replaced := graph.nodes["replaced"]; replaced == nil || replaced.vector[2] != -1 {
+		t.Error("replaced vector was overwritten by the snapshot")
+	}
+
+	if _, exists := graph.nodes["kept"]; !exists {
+		t.Error("unchanged vector is missing")
+	}
+
+	if !graph.isReady() || graph.changed != nil {
+		t.Error("graph isn't ready after the build")
+	}
+}
+
+func BenchmarkHNSWSearch(b *testing.B) {
+	graph, vectors, queries := hnswFixture(10000, 256, 100)
+
+	for i := 0; b.Loop(); i++ {
+		graph.search(queries[i%len(queries)], recallK)
+	}
+
+	b.ReportMetric(heldOutRecall(graph, vectors, queries), fmt.Sprintf("recall@%d", recallK))
+}
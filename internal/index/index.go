@@ -2,11 +2,19 @@ package index
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/cespare/xxhash"
 	"github.com/philippgille/chromem-go"
 	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+	"log"
+	"maps"
+	"math"
 	"os"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,125 +22,374 @@ import (
 )
 
 const (
-	minSimilarity = 0.3
-	maxResults    = 30
+	// MaxGrepMatches caps the number of chunks GrepChunks returns
+	MaxGrepMatches = 100
+
+	// Chunks live in one collection per file type, named with this prefix
+	collectionPrefix = "code-chunks-"
+	// legacyCollection held chunks of all file types in older indexes
+	legacyCollection = "code-chunks"
+
+	// rrfK dampens the influence of top ranks in reciprocal rank fusion
+	rrfK = 60
 )
 
+// partitions are the file types that get their own chunk store
+var partitions = []parser.FileType{parser.FileTypeSrc, parser.FileTypeTests, parser.FileTypeDocs}
+
+// FileMetadata describes an indexed file & its chunks
+type FileMetadata struct {
+	Hash     string           `json:"hash"`     // content hash when parsed
+	ModTime  int64            `json:"mod_time"` // modification time when parsed, in Unix nanoseconds
+	Size     int64            `json:"size"`     // size when parsed
+	Language string           `json:"language"`
+	Chunks   []*ChunkMetadata `json:"chunks"`
+}
+
 type ChunkMetadata struct {
-	Type     string // chunk type (src, docs, etc)
-	Path     string // hierarchical path: Class::method
-	ParsedAt int64  // when chunk was parsed
+	Type      string `json:"type"`                // chunk type (src, docs, etc)
+	Path      string `json:"path"`                // hierarchical path: Class::method
+	ParsedAt  int64  `json:"parsed_at"`           // when chunk was parsed
+	Embedding string `json:"embedding,omitempty"` // embedding cache key
+}
+
+// Config holds the settings used to open an Index
+type Config struct {
+	Embedder     EmbedderConfig
+	Storage      StorageConfig
+	ANN          ANNConfig
+	Quantization QuantizationConfig
+	Parsers      map[string]string // language -> parser spec fingerprint, chunks are re-extracted when it changes
+}
+
+// ConfigFromEnv reads the index settings from the environment
+func ConfigFromEnv() Config {
+	return Config{
+		Embedder:     EmbedderConfigFromEnv(),
+		Storage:      StorageConfigFromEnv(),
+		ANN:          ANNConfigFromEnv(),
+		Quantization: QuantizationConfigFromEnv(),
+	}
 }
 
 type Index struct {
 	workspaceRoot string
-	collection    *chromem.Collection
-
-	cache   map[string][]*ChunkMetadata
-	cacheMu sync.RWMutex
+	embedder      Embedder
+	schema        *schema
+	embeddings    *embeddingCache
+	db            *chromem.DB
+	stores        map[string]VectorStore // file type -> chunk store
+	closeStores   func() error
+	graphs        map[string]*hnswGraph // file type -> ANN graph, empty for exact search
+	lexical       *bm25Index
+	symbols       *symbolTable
+	loaded        chan struct{}  // closed once the keyword & symbol indexes hold the stored chunks
+	background    sync.WaitGroup // chunk loading & reconciling, waited for on close
+
+	quantizationRecall recallMeasurement
+
+	dbPath        string
+	cache         map[string]*FileMetadata // file path -> metadata, persisted in the manifest
+	manifestDirty bool                     // cache changed since the manifest was saved
+	released      []string                 // embedding cache keys of removed chunks, pruned on save
+	dropped       map[string][]string      // file path -> embedding cache keys of chunks a migration dropped, kept until it's re-indexed
+	cacheMu       sync.RWMutex
+
+	// Held by Index until its chunks reference their cached embeddings, &
+	// exclusively while pruning them
+	embeddingsMu sync.RWMutex
 }
 
-func New(ctx context.Context, workspaceRoot string) (*Index, error) {
-	db, err := chromem.NewPersistentDB(".codesearch/db", false)
+func New(ctx context.Context, workspaceRoot string, cfg Config) (*Index, error) {
+	embedder, err := NewEmbedder(cfg.Embedder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedder: %w", err)
+	}
+
+	if cfg.Embedder.Provider == ProviderOffline {
+		log.Println("Using offline lexical embeddings, configure an embedding provider for semantic search")
+	}
+
+	switch cfg.Quantization.Method {
+	case "", QuantizationNone:
+		cfg.Quantization.Method = QuantizationNone
+	case QuantizationInt8, QuantizationBinary:
+	default:
+		return nil, fmt.Errorf("unknown embedding quantization: %s", cfg.Quantization.Method)
+	}
+
+	dbPath, err := cfg.Storage.dbPath(workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := chromem.NewPersistentDB(dbPath, cfg.Storage.Compress)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create vector db: %w", err)
 	}
 
-	collection, err := db.GetOrCreateCollection("code-chunks", nil, nil)
+	// Chunks are embedded through the cache, only changed sources hit the provider
+	embeddings, err := newEmbeddingCache(ctx, db, dbPath, embedder)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create vector db collection: %w", err)
+		return nil, err
+	}
+
+	if db.GetCollection(legacyCollection, nil) != nil {
+		log.Println("Dropping index from an older version, the workspace will be re-indexed")
+		err = db.DeleteCollection(legacyCollection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to drop legacy vector db collection: %w", err)
+		}
+	}
+
+	stores, closeStores, err := openStores(db, dbPath, cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	current := newSchema(embedder, cfg.Quantization.Method, cfg.Parsers)
+	dropped, err := migrate(ctx, db, stores, current)
+	if err != nil {
+		closeStores()
+		return nil, err
+	}
+
+	if cfg.Quantization.Method != QuantizationNone {
+		// Full-precision embeddings are only kept in the embedding cache
+		for fileType, store := range stores {
+			stores[fileType], err = newQuantizedStore(ctx, store, cfg.Quantization, embeddings)
+			if err != nil {
+				closeStores()
+				return nil, err
+			}
+		}
 	}
 
 	idx := &Index{
 		workspaceRoot: workspaceRoot,
-		collection:    collection,
-		cache:         map[string][]*ChunkMetadata{},
+		embedder:      embedder,
+		embeddings:    embeddings,
+		schema:        current,
+		db:            db,
+		stores:        stores,
+		closeStores:   closeStores,
+		graphs:        map[string]*hnswGraph{},
+		lexical:       newBM25Index(),
+		symbols:       newSymbolTable(),
+		dbPath:        dbPath,
+		cache:         map[string]*FileMetadata{},
+		dropped:       map[string][]string{},
+		loaded:        make(chan struct{}),
 	}
 
-	idx.loadCache(ctx)
+	idx.loadCache(ctx, dropped)
+
+	switch cfg.ANN.Method {
+	case "", ANNExact:
+	case ANNHNSW:
+		idx.openGraphs(ctx, cfg.ANN.EfSearch)
+	default:
+		closeStores()
+		return nil, fmt.Errorf("unknown nearest neighbour search method: %s", cfg.ANN.Method)
+	}
+
+	idx.background.Add(1)
+	go func() {
+		defer idx.background.Done()
+		idx.reconcile(ctx)
+	}()
 
 	return idx, nil
 }
 
-func (idx *Index) loadCache(ctx context.Context) {
-	idx.cacheMu.Lock()
-	defer idx.cacheMu.Unlock()
+// loadCache restores the file cache from the manifest, so startup doesn't read
+// every chunk, less the files whose chunks the migration dropped. Their cached
+// embeddings are kept until they're re-indexed. The keyword & symbol indexes
+// are built in the background. Without a manifest, e.g. after an import, the
+// file cache is rebuilt from the chunks before returning.
+func (idx *Index) loadCache(ctx context.Context, dropped func(language string) bool) {
+	m, err := idx.loadManifest()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to load index manifest: %v", err)
+	}
 
-	fileChunks := map[string][]*ChunkMetadata{}
-	chunkIDs := idx.collection.ListIDs(ctx)
-	for _, chunkID := range chunkIDs {
-		parts := strings.SplitN(chunkID, "::", 2)
-		if len(parts) != 2 {
+	if m == nil {
+		idx.loadChunks(ctx)
+		return
+	}
+
+	for filePath, file := range m.Files {
+		if !dropped(file.Language) {
 			continue
 		}
 
-		filePath := parts[0]
+		for _, chunk := range file.Chunks {
+			if chunk.Embedding != "" {
+				m.Dropped[filePath] = append(m.Dropped[filePath], chunk.Embedding)
+			}
+		}
+		delete(m.Files, filePath)
+		idx.manifestDirty = true
+	}
+
+	idx.cache = m.Files
+	idx.dropped = m.Dropped
+	idx.background.Add(1)
+	go func() {
+		defer idx.background.Done()
+		idx.loadChunks(ctx)
+	}()
+}
 
-		// Check if file still exists
-		_, err := os.Stat(filePath)
+// loadChunks builds the keyword & symbol indexes from the stored chunks, and
+// adds chunks missing from the file cache to it, e.g. those written after the
+// manifest was last saved. Writes & lookups wait until it's done.
+func (idx *Index) loadChunks(ctx context.Context) {
+	defer close(idx.loaded)
+
+	var docs []*chromem.Document
+	for _, store := range idx.stores {
+		storeDocs, err := store.List(ctx, false)
 		if err != nil {
-			where := map[string]string{"file": filePath}
-			idx.collection.Delete(ctx, where, nil)
+			log.Printf("Failed to list indexed chunks: %v", err)
 			continue
 		}
+		docs = append(docs, storeDocs...)
+	}
 
-		chunk, err := idx.GetChunk(ctx, chunkID)
-		if err != nil {
+	for _, doc := range docs {
+		chunk := chunkFromDocument(*doc)
+		idx.lexical.add(doc.ID, chunk.Path, chunk.Summary, chunk.Source)
+		if isSymbol(chunk.Kind) {
+			idx.symbols.add(doc.ID, chunk.Path)
+		}
+	}
+
+	idx.cacheMu.Lock()
+	defer idx.cacheMu.Unlock()
+
+	known := map[string]bool{}
+	for filePath, file := range idx.cache {
+		for _, chunk := range file.Chunks {
+			known[filePath+"::"+chunk.Path] = true
+		}
+	}
+
+	var missing int
+	for _, doc := range docs {
+		if known[doc.ID] {
 			continue
 		}
+		missing++
+
+		filePath := doc.Metadata["file"]
+		file, exists := idx.cache[filePath]
+		if !exists {
+			modTime, _ := strconv.ParseInt(doc.Metadata["modTime"], 10, 64)
+			size, _ := strconv.ParseInt(doc.Metadata["size"], 10, 64)
+			file = &FileMetadata{
+				Hash:     doc.Metadata["fileHash"],
+				ModTime:  modTime,
+				Size:     size,
+				Language: doc.Metadata["language"],
+			}
+			idx.cache[filePath] = file
+		}
 
-		fileChunks[filePath] = append(fileChunks[filePath], &ChunkMetadata{
-			Type:     chunk.Type,
-			Path:     chunk.Path,
-			ParsedAt: chunk.ParsedAt,
+		parsedAt, _ := strconv.ParseInt(doc.Metadata["parsedAt"], 10, 64)
+		file.Chunks = append(file.Chunks, &ChunkMetadata{
+			Type:      doc.Metadata["type"],
+			Path:      doc.Metadata["path"],
+			ParsedAt:  parsedAt,
+			Embedding: idx.embeddings.documentKey(*doc),
 		})
 	}
 
-	idx.cache = fileChunks
+	if missing > 0 {
+		log.Printf("Adding %d chunks missing from the index manifest", missing)
+		idx.manifestDirty = true
+	}
+}
+
+// waitLoaded blocks until the keyword & symbol indexes hold the stored chunks
+func (idx *Index) waitLoaded(ctx context.Context) error {
+	select {
+	case <-idx.loaded:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
+// IsStale reports whether a file's content changed since it was indexed. Files
+// with an unchanged mtime & size are assumed unchanged, others are hashed, so
+// checkouts & touches that keep the content don't cause re-indexing.
 func (idx *Index) IsStale(filePath string) bool {
-	fileInfo, err := os.Stat(filePath)
+	fullPath := filepath.Join(idx.workspaceRoot, filePath)
+	fileInfo, err := os.Stat(fullPath)
 	if err != nil {
 		return true
 	}
 
 	idx.cacheMu.RLock()
-	defer idx.cacheMu.RUnlock()
+	file, exists := idx.cache[filePath]
+	var hash string
+	var sameStat bool
+	if exists {
+		hash = file.Hash
+		sameStat = file.ModTime == fileInfo.ModTime().UnixNano() && file.Size == fileInfo.Size()
+	}
+	idx.cacheMu.RUnlock()
 
-	chunks, exists := idx.cache[filePath]
-	if !exists || len(chunks) == 0 {
+	if !exists || hash == "" {
 		return true
 	}
 
-	// Find the maximum parsedAt timestamp among all chunks
-	var maxParsedAt int64
-	for _, chunk := range chunks {
-		if chunk.ParsedAt > maxParsedAt {
-			maxParsedAt = chunk.ParsedAt
-		}
+	if sameStat {
+		return false
 	}
 
-	return fileInfo.ModTime().Unix() > maxParsedAt
+	source, err := os.ReadFile(fullPath)
+	if err != nil || contentHash(source) != hash {
+		return true
+	}
+
+	// Remember the new mtime, so the file isn't hashed again
+	idx.cacheMu.Lock()
+	defer idx.cacheMu.Unlock()
+
+	file.ModTime = fileInfo.ModTime().UnixNano()
+	file.Size = fileInfo.Size()
+	idx.manifestDirty = true
+
+	return false
 }
 
+// Index replaces the chunks of a file with its parsed ones. They're embedded
+// first & the old chunks replaced in one store write, so a failure leaves the
+// file's old chunks in place.
 func (idx *Index) Index(ctx context.Context, file *parser.File) error {
-	err := idx.Remove(ctx, file.Path)
+	idx.embeddingsMu.RLock()
+	defer idx.embeddingsMu.RUnlock()
+
+	err := idx.waitLoaded(ctx)
 	if err != nil {
 		return err
 	}
 
-	if len(file.Chunks) == 0 {
-		return nil
-	}
+	fileHash := contentHash(file.Source)
+	pkg := packageName(file)
 
-	docs := []chromem.Document{}
+	docs := map[string][]chromem.Document{} // file type -> documents
+	embeddingKeys := map[string]string{}    // chunk ID -> embedding cache key
 	for _, chunk := range file.Chunks {
 		doc := chromem.Document{
 			ID: chunk.ID(),
 			Metadata: map[string]string{
 				"file":        file.Path,
+				"language":    file.Language,
 				"type":        chunk.Type,
+				"kind":        string(chunk.Kind),
 				"path":        chunk.Path,
 				"summary":     chunk.Summary,
 				"startLine":   strconv.Itoa(int(chunk.StartLine)),
@@ -140,101 +397,489 @@ func (idx *Index) Index(ctx context.Context, file *parser.File) error {
 				"endLine":     strconv.Itoa(int(chunk.EndLine)),
 				"endColumn":   strconv.Itoa(int(chunk.EndColumn)),
 				"parsedAt":    strconv.FormatInt(chunk.ParsedAt, 10),
+				"fileHash":    fileHash,
+				"modTime":     strconv.FormatInt(file.ModTime, 10),
+				"size":        strconv.FormatInt(file.Size, 10),
+				"package":     pkg,
+				"parentDoc":   parentDoc(file, chunk),
 			},
 			Content: chunk.Source,
 		}
 
-		docs = append(docs, doc)
+		docs[chunk.Type] = append(docs[chunk.Type], doc)
+		embeddingKeys[doc.ID] = idx.embeddings.documentKey(doc)
 	}
 
-	err = idx.collection.AddDocuments(ctx, docs, runtime.NumCPU())
-	if err != nil {
-		return fmt.Errorf("failed to add documents to vector db: %w", err)
+	for fileType, typeDocs := range docs {
+		if _, exists := idx.stores[fileType]; !exists {
+			return fmt.Errorf("no chunk store for file type %s", fileType)
+		}
+
+		err = idx.embed(ctx, typeDocs)
+		if err != nil {
+			return fmt.Errorf("failed to embed chunks: %w", err)
+		}
 	}
 
 	idx.cacheMu.Lock()
 	defer idx.cacheMu.Unlock()
 
-	chunkMetadata := make([]*ChunkMetadata, 0, len(file.Chunks))
+	// Chunks of files the cache doesn't know are looked up by metadata, in
+	// case it missed them
+	oldIDs := map[string][]string{} // file type -> chunk IDs
+	oldFile, exists := idx.cache[file.Path]
+	if !exists {
+		for _, store := range idx.stores {
+			err := store.DeleteFile(ctx, file.Path)
+			if err != nil {
+				return fmt.Errorf("failed to remove documents from vector db: %w", err)
+			}
+		}
+	} else {
+		for _, chunk := range oldFile.Chunks {
+			oldIDs[chunk.Type] = append(oldIDs[chunk.Type], file.Path+"::"+chunk.Path)
+		}
+	}
+
+	for fileType, store := range idx.stores {
+		if len(oldIDs[fileType]) == 0 && len(docs[fileType]) == 0 {
+			continue
+		}
+
+		err = store.Replace(ctx, oldIDs[fileType], docs[fileType])
+		if err != nil {
+			return fmt.Errorf("failed to add documents to vector db: %w", err)
+		}
+
+		if graph, exists := idx.graphs[fileType]; exists {
+			graph.remove(oldIDs[fileType]...)
+			for _, doc := range docs[fileType] {
+				graph.insert(doc.ID, doc.Embedding)
+			}
+		}
+	}
+
+	if exists {
+		for _, chunk := range oldFile.Chunks {
+			id := file.Path + "::" + chunk.Path
+			idx.lexical.remove(id)
+			idx.symbols.remove(id)
+			if chunk.Embedding != "" {
+				idx.released = append(idx.released, chunk.Embedding)
+			}
+		}
+	}
+
 	for _, chunk := range file.Chunks {
-		chunkMetadata = append(chunkMetadata, &ChunkMetadata{
-			Type:     chunk.Type,
-			Path:     chunk.Path,
-			ParsedAt: chunk.ParsedAt,
+		idx.lexical.add(chunk.ID(), chunk.Path, chunk.Summary, chunk.Source)
+		if isSymbol(chunk.Kind) {
+			idx.symbols.add(chunk.ID(), chunk.Path)
+		}
+	}
+
+	if len(file.Chunks) == 0 {
+		delete(idx.cache, file.Path)
+		delete(idx.dropped, file.Path)
+		idx.manifestDirty = true
+		return nil
+	}
+
+	fileMetadata := &FileMetadata{
+		Hash:     fileHash,
+		ModTime:  file.ModTime,
+		Size:     file.Size,
+		Language: file.Language,
+		Chunks:   make([]*ChunkMetadata, 0, len(file.Chunks)),
+	}
+	for _, chunk := range file.Chunks {
+		fileMetadata.Chunks = append(fileMetadata.Chunks, &ChunkMetadata{
+			Type:      chunk.Type,
+			Path:      chunk.Path,
+			ParsedAt:  chunk.ParsedAt,
+			Embedding: embeddingKeys[chunk.ID()],
 		})
 	}
-	idx.cache[file.Path] = chunkMetadata
+	idx.cache[file.Path] = fileMetadata
+	delete(idx.dropped, file.Path)
+	idx.manifestDirty = true
 
 	return nil
 }
 
-func (idx *Index) Remove(ctx context.Context, filePath string) error {
-	where := map[string]string{"file": filePath}
-	err := idx.collection.Delete(ctx, where, nil)
+// Remove drops files from the index. Chunks of known files are deleted by ID,
+// unknown files are looked up by metadata in case the cache missed them.
+func (idx *Index) Remove(ctx context.Context, filePaths ...string) error {
+	err := idx.waitLoaded(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to remove documents from vector db: %w", err)
+		return err
 	}
 
 	idx.cacheMu.Lock()
 	defer idx.cacheMu.Unlock()
 
-	delete(idx.cache, filePath)
+	ids := map[string][]string{} // file type -> chunk IDs
+	for _, filePath := range filePaths {
+		file, exists := idx.cache[filePath]
+		if !exists {
+			for _, store := range idx.stores {
+				err := store.DeleteFile(ctx, filePath)
+				if err != nil {
+					return fmt.Errorf("failed to remove documents from vector db: %w", err)
+				}
+			}
+			continue
+		}
+
+		for _, chunk := range file.Chunks {
+			id := filePath + "::" + chunk.Path
+			ids[chunk.Type] = append(ids[chunk.Type], id)
+			idx.lexical.remove(id)
+			idx.symbols.remove(id)
+			if chunk.Embedding != "" {
+				idx.released = append(idx.released, chunk.Embedding)
+			}
+		}
+		delete(idx.cache, filePath)
+		idx.manifestDirty = true
+	}
+
+	for fileType, typeIDs := range ids {
+		store, exists := idx.stores[fileType]
+		if !exists {
+			continue
+		}
+
+		err := store.Delete(ctx, typeIDs...)
+		if err != nil {
+			return fmt.Errorf("failed to remove documents from vector db: %w", err)
+		}
+
+		if graph, exists := idx.graphs[fileType]; exists {
+			graph.remove(typeIDs...)
+		}
+	}
 
 	return nil
 }
 
-func (idx *Index) Search(ctx context.Context, query string, fileTypes []string) ([]string, error) {
-	if len(fileTypes) == 0 {
-		fileTypes = []string{"src", "docs"}
+// Search ranks chunks by fusing vector similarity with BM25 keyword relevance,
+// so queries match both concepts and exact identifiers
+func (idx *Index) Search(ctx context.Context, query string, opts SearchOptions) (*SearchPage, error) {
+	opts = opts.normalize(DefaultLimit)
+	if len(opts.FileTypes) == 0 {
+		opts.FileTypes = []string{"src", "docs"}
 	}
 
-	// chromem-go doesn't support OR filtering, for now fetch more & filter manually
-	results, err := idx.collection.Query(ctx, query, len(fileTypes)*maxResults, nil, nil)
+	err := opts.validate()
 	if err != nil {
-		return nil, fmt.Errorf("failed to perform similarity search: %w", err)
+		return nil, err
+	}
+
+	err = idx.waitLoaded(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	allowedTypes := make(map[string]bool)
-	for _, ft := range fileTypes {
-		allowedTypes[ft] = true
+	// Queries bypass the embedding cache, they are rarely repeated verbatim
+	embedding, err := idx.embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	// Each file type has its own store, so a pool per store always
+	// holds the top matches of the requested types
+	nCandidates := searchPool
+	if opts.filtersNarrowly() {
+		nCandidates = math.MaxInt
 	}
 
-	return idx.formatSearchResults(ctx, results, minSimilarity, maxResults, "", allowedTypes), nil
+	results, err := idx.queryStores(ctx, embedding, opts.FileTypes, nCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform similarity search: %w", err)
+	}
+
+	semantic := idx.filterResults(ctx, results, opts, "", searchPool)
+	lexical := idx.lexicalSearch(ctx, query, embedding, opts)
+
+	ranked, relevance := fuseRankings(searchPool, semantic, lexical)
+
+	return paginate(idx.diversify(ctx, ranked, relevance, opts), opts), nil
 }
 
-func (idx *Index) FindSimilarChunks(ctx context.Context, chunkID string) ([]string, error) {
-	doc, err := idx.collection.GetByID(ctx, chunkID)
+// FindSimilarChunks ranks chunks by vector similarity to the given chunk
+func (idx *Index) FindSimilarChunks(ctx context.Context, chunkID string, opts SearchOptions) (*SearchPage, error) {
+	opts = opts.normalize(DefaultSimilarLimit)
+
+	err := opts.validate()
 	if err != nil {
-		return nil, fmt.Errorf("chunk not found: %s", chunkID)
+		return nil, err
 	}
 
-	results, err := idx.collection.QueryEmbedding(ctx, doc.Embedding, 10, nil, nil)
+	doc, err := idx.getDocument(ctx, chunkID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileTypes := opts.FileTypes
+	if len(fileTypes) == 0 {
+		fileTypes = slices.Collect(maps.Keys(idx.stores))
+	}
+
+	// One extra result, since the chunk itself is the closest match
+	nCandidates := opts.window() + 1
+	if opts.filtersNarrowly() {
+		nCandidates = math.MaxInt
+	}
+
+	results, err := idx.queryStores(ctx, doc.Embedding, fileTypes, nCandidates)
 	if err != nil {
 		return nil, fmt.Errorf("failed to perform similarity search: %w", err)
 	}
 
-	return idx.formatSearchResults(ctx, results, 2*minSimilarity, 10, chunkID, nil), nil
+	similar := idx.filterResults(ctx, results, opts, chunkID, opts.window())
+
+	return paginate(similar, opts), nil
+}
+
+// FindSymbol looks up chunks by symbol name, e.g. "processFiles" or
+// "Analyzer::processFiles", closest matches first
+func (idx *Index) FindSymbol(ctx context.Context, name string, match SymbolMatch) ([]*SymbolResult, error) {
+	if name == "" {
+		return nil, fmt.Errorf("symbol name is empty")
+	}
+
+	err := idx.waitLoaded(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*SymbolResult{}
+	for _, hit := range idx.symbols.lookup(name, match) {
+		if len(results) >= DefaultLimit {
+			break
+		}
+
+		chunk, err := idx.GetChunk(ctx, hit.id)
+		if err != nil {
+			continue
+		}
+
+		results = append(results, &SymbolResult{
+			SearchResult: newSearchResult(chunk, 0),
+			Distance:     hit.distance,
+			Exact:        hit.exact,
+		})
+	}
+
+	return results, nil
 }
 
-func (idx *Index) formatSearchResults(
+// GrepChunks matches a regular expression, or a literal string, against the
+// lines of every indexed chunk and returns the matching chunks with line numbers
+func (idx *Index) GrepChunks(
+	ctx context.Context,
+	pattern string,
+	literal bool,
+	ignoreCase bool,
+	fileTypes []string,
+) ([]string, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is empty")
+	}
+
+	if literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	if len(fileTypes) == 0 {
+		fileTypes = []string{"src", "docs"}
+	}
+
+	idx.cacheMu.RLock()
+	var chunkIDs []string
+	for filePath, file := range idx.cache {
+		for _, chunk := range file.Chunks {
+			if slices.Contains(fileTypes, chunk.Type) {
+				chunkIDs = append(chunkIDs, filePath+"::"+chunk.Path)
+			}
+		}
+	}
+	idx.cacheMu.RUnlock()
+
+	var matches []*parser.Chunk
+	matchedLines := map[string][]uint{}
+	for _, chunkID := range chunkIDs {
+		chunk, err := idx.GetChunk(ctx, chunkID)
+		if err != nil {
+			continue
+		}
+
+		for i, line := range strings.Split(chunk.Source, "\n") {
+			if re.MatchString(line) {
+				matchedLines[chunkID] = append(matchedLines[chunkID], chunk.StartLine+uint(i))
+			}
+		}
+
+		if len(matchedLines[chunkID]) > 0 {
+			matches = append(matches, chunk)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].File != matches[j].File {
+			return matches[i].File < matches[j].File
+		}
+		return matches[i].StartLine < matches[j].StartLine
+	})
+
+	if len(matches) > MaxGrepMatches {
+		matches = matches[:MaxGrepMatches]
+	}
+
+	results := make([]string, 0, len(matches))
+	for _, chunk := range matches {
+		lineNumbers := matchedLines[chunk.ID()]
+
+		var lines string
+		if len(lineNumbers) == 1 {
+			lines = fmt.Sprintf("line %d", lineNumbers[0])
+		} else {
+			numbers := make([]string, 0, len(lineNumbers))
+			for _, n := range lineNumbers {
+				numbers = append(numbers, strconv.Itoa(int(n)))
+			}
+			lines = "lines " + strings.Join(numbers, ", ")
+		}
+
+		results = append(results, fmt.Sprintf("%s | %s [%s]", chunk.ID(), chunk.Summary, lines))
+	}
+
+	return results, nil
+}
+
+// queryStores runs a similarity search on the stores of the given file types in
+// parallel, merging up to nResults matches of each
+func (idx *Index) queryStores(
+	ctx context.Context,
+	embedding []float32,
+	fileTypes []string,
+	nResults int,
+) ([]chromem.Result, error) {
+	var (
+		results  []chromem.Result
+		firstErr error
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+	)
+
+	for _, fileType := range fileTypes {
+		store, exists := idx.stores[fileType]
+		if !exists {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			found, err := idx.queryStore(ctx, fileType, store, embedding, nResults)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			results = append(results, found...)
+		}()
+	}
+
+	wg.Wait()
+	return results, firstErr
+}
+
+// queryStore runs a similarity search on a store, through its HNSW graph when
+// one is ready and the search doesn't cover the whole store anyway
+func (idx *Index) queryStore(
+	ctx context.Context,
+	fileType string,
+	store VectorStore,
+	embedding []float32,
+	nResults int,
+) ([]chromem.Result, error) {
+	graph, exists := idx.graphs[fileType]
+	if exists && graph.isReady() && nResults < store.Count() {
+		return graph.search(embedding, nResults), nil
+	}
+
+	return store.Query(ctx, embedding, nResults)
+}
+
+// embed computes the embeddings of documents in parallel, through the cache so
+// only changed sources hit the provider
+func (idx *Index) embed(ctx context.Context, docs []chromem.Document) error {
+	var (
+		firstErr error
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+	)
+
+	workers := make(chan struct{}, runtime.NumCPU())
+	for i := range docs {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			embedding, err := idx.embeddings.Embed(ctx, embeddingText(docs[i]))
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			docs[i].Embedding = embedding
+		}()
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// filterResults resolves similarity search results into search results, ordered
+// by similarity and keeping up to maxCount of those above the threshold &
+// passing the filters
+func (idx *Index) filterResults(
 	ctx context.Context,
 	results []chromem.Result,
-	minSimilarity float32,
-	maxCount int,
+	opts SearchOptions,
 	skipID string,
-	typeFilter map[string]bool,
-) []string {
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Similarity > results[j].Similarity
-	})
+	maxCount int,
+) []*SearchResult {
+	// Stores are queried in parallel, ties are ordered by ID so pages don't overlap
+	slices.SortFunc(results, byResultSimilarity)
 
-	paths := []string{}
+	filtered := []*SearchResult{}
 	for _, result := range results {
 		if result.ID == skipID {
 			continue
 		}
 
-		if result.Similarity < minSimilarity || len(paths) >= maxCount {
+		if result.Similarity < opts.MinScore || len(filtered) >= maxCount {
 			break
 		}
 
@@ -243,32 +888,113 @@ func (idx *Index) formatSearchResults(
 			continue
 		}
 
-		if typeFilter != nil && !typeFilter[chunk.Type] {
+		if !opts.accepts(chunk) {
 			continue
 		}
 
-		var lines string
-		if chunk.StartLine == chunk.EndLine {
-			lines = fmt.Sprintf("line %d", chunk.StartLine)
-		} else {
-			lines = fmt.Sprintf("lines %d-%d", chunk.StartLine, chunk.EndLine)
+		filtered = append(filtered, newSearchResult(chunk, result.Similarity))
+	}
+
+	return filtered
+}
+
+// lexicalSearch returns the search pool ranked by BM25 keyword relevance, scored
+// by their similarity to the query embedding like semantic results. The min
+// score doesn't apply, exact identifiers often embed far from the query.
+func (idx *Index) lexicalSearch(
+	ctx context.Context,
+	query string,
+	queryEmbedding []float32,
+	opts SearchOptions,
+) []*SearchResult {
+	results := []*SearchResult{}
+	for _, hit := range idx.lexical.search(query) {
+		if len(results) >= searchPool {
+			break
+		}
+
+		doc, err := idx.getDocument(ctx, hit.id)
+		if err != nil {
+			continue
 		}
 
-		paths = append(
-			paths,
-			fmt.Sprintf("%s | %s [%s]", result.ID, chunk.Summary, lines),
-		)
+		chunk := chunkFromDocument(doc)
+		if !opts.accepts(chunk) {
+			continue
+		}
+
+		results = append(results, newSearchResult(chunk, cosineSimilarity(queryEmbedding, doc.Embedding)))
 	}
 
-	return paths
+	return results
+}
+
+// fuseRankings merges ranked result lists with reciprocal rank fusion, so chunks
+// ranked well by several retrievers come first, returning at most maxCount
+// along with their fused scores
+func fuseRankings(maxCount int, rankings ...[]*SearchResult) ([]*SearchResult, []float64) {
+	scores := map[string]float64{}
+	results := map[string]*SearchResult{}
+	var order []string
+
+	for _, ranking := range rankings {
+		for rank, result := range ranking {
+			if _, seen := results[result.ID]; !seen {
+				results[result.ID] = result
+				order = append(order, result.ID)
+			}
+
+			scores[result.ID] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	// Stable, so ties keep the order of the earlier rankings
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	if len(order) > maxCount {
+		order = order[:maxCount]
+	}
+
+	fused := make([]*SearchResult, 0, len(order))
+	fusedScores := make([]float64, 0, len(order))
+	for _, id := range order {
+		fused = append(fused, results[id])
+		fusedScores = append(fusedScores, scores[id])
+	}
+
+	return fused, fusedScores
 }
 
 func (idx *Index) GetChunk(ctx context.Context, id string) (*parser.Chunk, error) {
-	doc, err := idx.collection.GetByID(ctx, id)
+	doc, err := idx.getDocument(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("chunk not found: %s", id)
+		return nil, err
+	}
+
+	return chunkFromDocument(doc), nil
+}
+
+// getDocument looks up a chunk's document in the stores
+func (idx *Index) getDocument(ctx context.Context, id string) (chromem.Document, error) {
+	for _, store := range idx.stores {
+		doc, err := store.Get(ctx, id)
+		if err == nil {
+			return doc, nil
+		}
 	}
 
+	return chromem.Document{}, fmt.Errorf("chunk not found: %s", id)
+}
+
+// contentHash returns the hash used to tell whether file contents changed
+func contentHash(source []byte) string {
+	return fmt.Sprintf("%x", xxhash.Sum64(source))
+}
+
+// chunkFromDocument restores a chunk from its vector db document
+func chunkFromDocument(doc chromem.Document) *parser.Chunk {
 	startLine, _ := strconv.Atoi(doc.Metadata["startLine"])
 	startColumn, _ := strconv.Atoi(doc.Metadata["startColumn"])
 	endLine, _ := strconv.Atoi(doc.Metadata["endLine"])
@@ -277,7 +1003,9 @@ func (idx *Index) GetChunk(ctx context.Context, id string) (*parser.Chunk, error
 
 	return &parser.Chunk{
 		File:        doc.Metadata["file"],
+		Language:    doc.Metadata["language"],
 		Type:        doc.Metadata["type"],
+		Kind:        parser.ChunkKind(doc.Metadata["kind"]),
 		Path:        doc.Metadata["path"],
 		Summary:     doc.Metadata["summary"],
 		Source:      doc.Content,
@@ -286,5 +1014,5 @@ func (idx *Index) GetChunk(ctx context.Context, id string) (*parser.Chunk, error
 		EndLine:     uint(endLine),
 		EndColumn:   uint(endColumn),
 		ParsedAt:    parsedAt,
-	}, nil
+	}
 }
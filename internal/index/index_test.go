@@ -0,0 +1,249 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+// testProvider counts the texts embedded by an embeddingServer & makes it fail
+type testProvider struct {
+	embedded atomic.Int64
+	failing  atomic.Bool
+}
+
+// embeddingServer stands in for an embedding provider, serving offline lexical
+// embeddings over the Ollama API
+func embeddingServer(t *testing.T) (EmbedderConfig, *testProvider) {
+	embedder := newLexicalEmbedder()
+	provider := &testProvider{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if provider.failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var body struct {
+			Input string `json:"input"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		provider.embedded.Add(1)
+		embedding, _ := embedder.Embed(r.Context(), body.Input)
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": [][]float32{embedding}})
+	}))
+	t.Cleanup(server.Close)
+
+	return EmbedderConfig{Provider: ProviderOllama, BaseURL: server.URL}, provider
+}
+
+// offlineConfig returns the config of an index with offline embeddings & its
+// db in the workspace
+func offlineConfig() Config {
+	return Config{Embedder: EmbedderConfig{Provider: ProviderOffline}}
+}
+
+// openIndex opens the index of a workspace & waits for the startup chunk load
+// & reconcile, so tests see their outcome
+func openIndex(t testing.TB, root string, cfg Config) *Index {
+	t.Helper()
+
+	idx, err := New(context.Background(), root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx.background.Wait()
+	return idx
+}
+
+// closeIndex closes an index, so the test can reopen it
+func closeIndex(t testing.TB, idx *Index) {
+	t.Helper()
+
+	err := idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testChunk describes a chunk of a test file
+type testChunk struct {
+	path   string
+	kind   parser.ChunkKind
+	source string
+}
+
+// writeFile writes a workspace file made of the chunks' sources & returns it
+// parsed into those chunks, as the analyzer would
+func writeFile(t testing.TB, root, filePath, language string, chunks ...testChunk) *parser.File {
+	t.Helper()
+
+	fileType := parser.FileTypeSrc
+	switch {
+	case strings.HasSuffix(filePath, "_test.go"):
+		fileType = parser.FileTypeTests
+	case strings.HasSuffix(filePath, ".md"):
+		fileType = parser.FileTypeDocs
+	}
+
+	var source strings.Builder
+	file := &parser.File{Path: filePath, Language: language}
+	line := uint(1)
+	for _, chunk := range chunks {
+		nLines := uint(strings.Count(chunk.source, "\n") + 1)
+		file.Chunks = append(file.Chunks, &parser.Chunk{
+			File:        filePath,
+			Language:    language,
+			Type:        string(fileType),
+			Kind:        chunk.kind,
+			Path:        chunk.path,
+			Summary:     strings.SplitN(chunk.source, "\n", 2)[0],
+			Source:      chunk.source,
+			StartLine:   line,
+			StartColumn: 1,
+			EndLine:     line + nLines - 1,
+			EndColumn:   1,
+		})
+		source.WriteString(chunk.source + "\n")
+		line += nLines
+	}
+	file.Source = []byte(source.String())
+
+	fullPath := filepath.Join(root, filePath)
+	err := os.MkdirAll(filepath.Dir(fullPath), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(fullPath, file.Source, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.ModTime = info.ModTime().UnixNano()
+	file.Size = info.Size()
+
+	return file
+}
+
+// funcChunk is a chunk holding a Go function
+func funcChunk(name, body string) testChunk {
+	return testChunk{
+		path:   name,
+		kind:   parser.KindFunction,
+		source: "func " + name + "() {\n\t" + body + "\n}",
+	}
+}
+
+// indexFiles indexes parsed files, failing the test on the first error
+func indexFiles(t testing.TB, idx *Index, files ...*parser.File) {
+	t.Helper()
+
+	for _, file := range files {
+		err := idx.Index(context.Background(), file)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, fullPath string)
+		stale  bool
+	}{
+		{
+			name:   "unchanged",
+			change: func(t *testing.T, fullPath string) {},
+		},
+		{
+			name: "touched",
+			change: func(t *testing.T, fullPath string) {
+				later := time.Now().Add(time.Hour)
+				err := os.Chtimes(fullPath, later, later)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "same size, other content",
+			change: func(t *testing.T, fullPath string) {
+				source, err := os.ReadFile(fullPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = os.WriteFile(fullPath, bytes.Replace(source, []byte("nil"), []byte("err"), 1), 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// Coarse mtimes could hide the write
+				later := time.Now().Add(time.Hour)
+				err = os.Chtimes(fullPath, later, later)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			stale: true,
+		},
+		{
+			name: "deleted",
+			change: func(t *testing.T, fullPath string) {
+				err := os.Remove(fullPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			stale: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			idx := openIndex(t, root, offlineConfig())
+			defer closeIndex(t, idx)
+
+			file := writeFile(t, root, "a.go", "go", funcChunk("openStore", "return nil"))
+			if !idx.IsStale(file.Path) {
+				t.Error("a file that was never indexed isn't stale")
+			}
+
+			indexFiles(t, idx, file)
+			test.change(t, filepath.Join(root, file.Path))
+
+			if stale := idx.IsStale(file.Path); stale != test.stale {
+				t.Errorf("stale %v, expected %v", stale, test.stale)
+			}
+
+			// A touched file's new mtime is remembered, so it isn't hashed again
+			info, err := os.Stat(filepath.Join(root, file.Path))
+			if err == nil && !test.stale && idx.cache[file.Path].ModTime != info.ModTime().UnixNano() {
+				t.Error("the file's mtime wasn't updated")
+			}
+		})
+	}
+}
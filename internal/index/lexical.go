@@ -0,0 +1,71 @@
+package index
+
+import (
+	"context"
+	"math"
+
+	"github.com/cespare/xxhash"
+)
+
+const (
+	lexicalDimensions    = 1024
+	lexicalTrigramWeight = 0.3
+)
+
+// lexicalEmbedder is a dependency-free embedder that hashes identifier
+// sub-tokens and character trigrams into a fixed-size vector. It has no
+// notion of meaning, but ranks chunks sharing vocabulary with the query
+// well enough to work without any embedding service.
+type lexicalEmbedder struct{}
+
+func newLexicalEmbedder() *lexicalEmbedder {
+	return &lexicalEmbedder{}
+}
+
+func (e *lexicalEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	features := map[string]float64{}
+	for _, term := range tokenize(text) {
+		features[term]++
+
+		// Trigrams let related spellings match, e.g. "index" & "indexing"
+		padded := "^" + term + "$"
+		for i := 0; i+3 <= len(padded); i++ {
+			features["#"+padded[i:i+3]] += lexicalTrigramWeight
+		}
+	}
+
+	vector := make([]float32, lexicalDimensions)
+	for feature, count := range features {
+		hash := xxhash.Sum64String(feature)
+		weight := float32(1 + math.Log(1+count))
+
+		// The hash's top bit picks a sign so collisions tend to cancel out
+		if hash>>63 == 1 {
+			weight = -weight
+		}
+
+		vector[hash%lexicalDimensions] += weight
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+
+	if norm == 0 {
+		// Similarity search requires a non-zero vector
+		vector[0] = 1
+		return vector, nil
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+
+	return vector, nil
+}
+
+func (e *lexicalEmbedder) Model() string {
+	return ProviderOffline + ":lexical-v1"
+}
@@ -0,0 +1,80 @@
+package index
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text  string
+		terms []string
+	}{
+		{"parseHTTPRequest", []string{"parsehttprequest", "parse", "http", "request"}},
+		{"HTTPServer", []string{"httpserver", "http", "server"}},
+		{"load_config_v2", []string{"loadconfigv2", "load", "config"}},
+		{"sha256Sum", []string{"sha256sum", "sha", "256", "sum"}},
+		{"utf8Decode", []string{"utf8decode", "utf", "decode"}},
+		{"a + b == sum", []string{"sum"}},
+		{"Close() error", []string{"close", "error"}},
+	}
+
+	for _, test := range tests {
+		if terms := tokenize(test.text); !slices.Equal(terms, test.terms) {
+			t.Errorf("tokenized %q as %q, expected %q", test.text, terms, test.terms)
+		}
+	}
+}
+
+func TestLexicalEmbedder(t *testing.T) {
+	ctx := context.Background()
+	embedder := newLexicalEmbedder()
+
+	embed := func(text string) []float32 {
+		embedding, err := embedder.Embed(ctx, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(embedding) != lexicalDimensions {
+			t.Fatalf("embedding of %d dimensions, expected %d", len(embedding), lexicalDimensions)
+		}
+
+		var norm float64
+		for _, v := range embedding {
+			norm += float64(v) * float64(v)
+		}
+		if math.Abs(norm-1) > 1e-4 {
+			t.Errorf("embedding of %q has norm %.4f", text, math.Sqrt(norm))
+		}
+
+		return embedding
+	}
+
+	query := embed("index files")
+	tests := []struct {
+		name   string
+		closer string
+		other  string
+	}{
+		{"shared identifier parts", "func indexFiles(paths []string)", "func renderTemplate(w io.Writer)"},
+		{"related spellings", "indexing file paths", "rendering templates"},
+	}
+
+	for _, test := range tests {
+		closer := cosineSimilarity(query, embed(test.closer))
+		other := cosineSimilarity(query, embed(test.other))
+		if closer <= other {
+			t.Errorf("%s: similarity %.3f to %q, %.3f to %q", test.name, closer, test.closer, other, test.other)
+		}
+	}
+
+	// Text without terms still gets a non-zero embedding
+	embed("+ - * /")
+
+	if !slices.Equal(embed("index files"), query) {
+		t.Error("embeddings aren't deterministic")
+	}
+}
@@ -0,0 +1,246 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// manifestFile holds the file cache next to the vector db, so startup reads it
+// in one go instead of inspecting every chunk
+const manifestFile = "manifest.json"
+
+type manifest struct {
+	Files   map[string]*FileMetadata `json:"files"`             // file path -> metadata
+	Dropped map[string][]string      `json:"dropped,omitempty"` // file path -> embedding cache keys of chunks a migration dropped
+}
+
+// loadManifest reads the file metadata saved by saveManifest
+func (idx *Index) loadManifest() (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(idx.dbPath, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode index manifest: %w", err)
+	}
+
+	if m.Files == nil {
+		m.Files = map[string]*FileMetadata{}
+	}
+	if m.Dropped == nil {
+		m.Dropped = map[string][]string{}
+	}
+
+	return &m, nil
+}
+
+// Save persists the manifest & HNSW graphs, the vector db persists documents as
+// they're added, and prunes the cached embeddings of removed chunks
+func (idx *Index) Save() error {
+	err := idx.saveManifest()
+	if err != nil {
+		return err
+	}
+
+	err = idx.pruneEmbeddings()
+	if err != nil {
+		return err
+	}
+
+	return idx.saveGraphs()
+}
+
+// saveManifest persists the file metadata if it changed since the last save
+func (idx *Index) saveManifest() error {
+	idx.cacheMu.Lock()
+	defer idx.cacheMu.Unlock()
+
+	if !idx.manifestDirty {
+		return nil
+	}
+
+	data, err := json.Marshal(manifest{Files: idx.cache, Dropped: idx.dropped})
+	if err != nil {
+		return fmt.Errorf("failed to encode index manifest: %w", err)
+	}
+
+	// Write & rename, so a crash never leaves a truncated manifest behind
+	path := filepath.Join(idx.dbPath, manifestFile)
+	err = os.WriteFile(path+".tmp", data, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	idx.manifestDirty = false
+	return nil
+}
+
+// reconcile drops files deleted since the index was last open. Changed & new
+// files are picked up by the workspace scan.
+func (idx *Index) reconcile(ctx context.Context) {
+	err := idx.waitLoaded(ctx)
+	if err != nil {
+		return
+	}
+
+	idx.cacheMu.RLock()
+	filePaths := make([]string, 0, len(idx.cache))
+	for filePath := range idx.cache {
+		filePaths = append(filePaths, filePath)
+	}
+	droppedPaths := slices.Collect(maps.Keys(idx.dropped))
+	idx.cacheMu.RUnlock()
+
+	var deleted []string
+	for _, filePath := range filePaths {
+		_, err := os.Stat(filepath.Join(idx.workspaceRoot, filePath))
+		if errors.Is(err, os.ErrNotExist) {
+			deleted = append(deleted, filePath)
+		}
+	}
+
+	// Deleted files a migration dropped won't be re-indexed, their embeddings
+	// needn't be kept
+	idx.cacheMu.Lock()
+	for _, filePath := range droppedPaths {
+		_, err := os.Stat(filepath.Join(idx.workspaceRoot, filePath))
+		if errors.Is(err, os.ErrNotExist) {
+			delete(idx.dropped, filePath)
+			idx.manifestDirty = true
+		}
+	}
+	idx.cacheMu.Unlock()
+
+	if len(deleted) > 0 {
+		log.Printf("Removing %d deleted files from the index", len(deleted))
+		err = idx.Remove(ctx, deleted...)
+		if err != nil {
+			log.Printf("Failed to remove deleted files: %v", err)
+			return
+		}
+	}
+
+	err = idx.saveManifest()
+	if err != nil {
+		log.Printf("Failed to save index manifest: %v", err)
+	}
+}
+
+// pruneEmbeddings drops the cached embeddings of removed chunks that no indexed
+// chunk shares. It's skipped while files are being indexed, their embeddings
+// may not be referenced yet.
+func (idx *Index) pruneEmbeddings() error {
+	if !idx.embeddingsMu.TryLock() {
+		return nil
+	}
+	defer idx.embeddingsMu.Unlock()
+
+	idx.cacheMu.Lock()
+	released := idx.released
+	idx.released = nil
+	var referenced map[string]bool
+	if len(released) > 0 {
+		referenced, _ = idx.referencedEmbeddings()
+	}
+	idx.cacheMu.Unlock()
+
+	var unused []string
+	for _, key := range released {
+		if !referenced[key] {
+			unused = append(unused, key)
+		}
+	}
+
+	return idx.embeddings.delete(unused...)
+}
+
+// PruneEmbeddings drops every cached embedding no indexed chunk references, e.g.
+// those of chunks removed since the last save or changed by a schema change.
+// Run it after a full workspace scan, the embeddings of chunks a migration
+// dropped are kept until their file is re-indexed. Manifests written before
+// chunks recorded their cache key can't tell, so nothing is pruned until
+// every chunk has one.
+func (idx *Index) PruneEmbeddings() error {
+	idx.embeddingsMu.Lock()
+	defer idx.embeddingsMu.Unlock()
+
+	idx.cacheMu.Lock()
+	referenced, complete := idx.referencedEmbeddings()
+	idx.released = nil
+	idx.cacheMu.Unlock()
+
+	if !complete {
+		return nil
+	}
+
+	keys, err := idx.embeddings.keys()
+	if err != nil {
+		return err
+	}
+
+	var unused []string
+	for _, key := range keys {
+		if !referenced[key] {
+			unused = append(unused, key)
+		}
+	}
+
+	if len(unused) > 0 {
+		log.Printf("Pruning %d unused cached embeddings", len(unused))
+	}
+
+	return idx.embeddings.delete(unused...)
+}
+
+// referencedEmbeddings returns the embedding cache keys of the indexed chunks &
+// of those awaiting re-indexing after a migration, and whether every indexed
+// chunk has one. The cache lock must be held.
+func (idx *Index) referencedEmbeddings() (map[string]bool, bool) {
+	referenced := map[string]bool{}
+	for _, keys := range idx.dropped {
+		for _, key := range keys {
+			referenced[key] = true
+		}
+	}
+
+	complete := true
+	for _, file := range idx.cache {
+		for _, chunk := range file.Chunks {
+			if chunk.Embedding == "" {
+				complete = false
+				continue
+			}
+			referenced[chunk.Embedding] = true
+		}
+	}
+
+	return referenced, complete
+}
+
+// Close waits for the startup reconcile, saves the index & closes its stores
+func (idx *Index) Close() error {
+	idx.background.Wait()
+
+	err := idx.Save()
+	if err != nil {
+		idx.closeStores()
+		return err
+	}
+
+	return idx.closeStores()
+}
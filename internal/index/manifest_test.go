@@ -0,0 +1,136 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// editManifest rewrites the saved manifest of a closed index
+func editManifest(t *testing.T, root string, edit func(m *manifest)) {
+	t.Helper()
+
+	path := filepath.Join(root, workspaceDBDir, manifestFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m manifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edit(&m)
+
+	data, err = json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, data, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManifestWarmStart(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, root string)
+		files  []string // indexed files after reopening
+	}{
+		{
+			name:   "unchanged",
+			change: func(t *testing.T, root string) {},
+			files:  []string{"a.go", "b.go"},
+		},
+		{
+			name: "chunks missing from the manifest",
+			change: func(t *testing.T, root string) {
+				editManifest(t, root, func(m *manifest) { delete(m.Files, "b.go") })
+			},
+			files: []string{"a.go", "b.go"},
+		},
+		{
+			name: "no manifest",
+			change: func(t *testing.T, root string) {
+				err := os.Remove(filepath.Join(root, workspaceDBDir, manifestFile))
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			files: []string{"a.go", "b.go"},
+		},
+		{
+			name: "corrupt manifest",
+			change: func(t *testing.T, root string) {
+				err := os.WriteFile(filepath.Join(root, workspaceDBDir, manifestFile), []byte("{"), 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			files: []string{"a.go", "b.go"},
+		},
+		{
+			name: "file deleted while closed",
+			change: func(t *testing.T, root string) {
+				err := os.Remove(filepath.Join(root, "b.go"))
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			files: []string{"a.go"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			idx := openIndex(t, root, offlineConfig())
+			indexFiles(t, idx,
+				writeFile(t, root, "a.go", "go", funcChunk("openStore", "return openDB(dir)")),
+				writeFile(t, root, "b.go", "go", funcChunk("closeStore", "return db.Close()")),
+			)
+			closeIndex(t, idx)
+
+			test.change(t, root)
+			idx = openIndex(t, root, offlineConfig())
+			defer closeIndex(t, idx)
+
+			files := slices.Sorted(maps.Keys(idx.cache))
+			if !slices.Equal(files, test.files) {
+				t.Errorf("indexed files %v, expected %v", files, test.files)
+			}
+
+			for _, filePath := range test.files {
+				if idx.IsStale(filePath) {
+					t.Errorf("%s is stale after reopening", filePath)
+				}
+			}
+
+			// The keyword & symbol indexes are rebuilt from the stored chunks
+			symbols, err := idx.FindSymbol(context.Background(), "closeStore", SymbolMatchExact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := len(symbols) == 1; found != slices.Contains(test.files, "b.go") {
+				t.Errorf("found symbols %v", symbols)
+			}
+
+			// Changes made at startup are saved
+			m, err := idx.loadManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Files) != len(test.files) {
+				t.Errorf("saved manifest holds %d files, expected %d", len(m.Files), len(test.files))
+			}
+		})
+	}
+}
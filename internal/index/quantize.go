@@ -0,0 +1,542 @@
+package index
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/philippgille/chromem-go"
+)
+
+const (
+	// QuantizationNone stores float32 embeddings, QuantizationInt8 stores a
+	// byte per dimension & QuantizationBinary a bit per dimension
+	QuantizationNone   = "none"
+	QuantizationInt8   = "int8"
+	QuantizationBinary = "binary"
+
+	// defaultRescore rescores this many times the requested results at full precision
+	defaultRescore = 4
+	// maxRescored caps the requested results that are rescored
+	maxRescored = searchPool
+
+	// quantizedKey holds the quantized embedding in the document metadata, the
+	// stored embedding is a placeholder
+	quantizedKey = "quantizedEmbedding"
+)
+
+// QuantizationConfig selects how chunk embeddings are compressed. Changing the
+// method rebuilds the index.
+type QuantizationConfig struct {
+	Method  string // none, int8 or binary
+	Rescore int    // multiple of the requested results rescored at full precision, 0 disables rescoring
+}
+
+// QuantizationConfigFromEnv reads the quantization settings from the environment
+func QuantizationConfigFromEnv() QuantizationConfig {
+	rescore := defaultRescore
+	if value, err := strconv.Atoi(os.Getenv("CODE_SEARCH_QUANTIZATION_RESCORE")); err == nil {
+		rescore = value
+	}
+
+	return QuantizationConfig{
+		Method:  cmp.Or(os.Getenv("CODE_SEARCH_QUANTIZATION"), QuantizationNone),
+		Rescore: rescore,
+	}
+}
+
+// quantizedVector is a compressed normalized embedding
+type quantizedVector struct {
+	file     string
+	language string
+	key      string // embedding cache key of the full-precision embedding
+	dims     int
+	binary   bool    // a bit per dimension, otherwise a signed byte
+	scale    float32 // int8 only, the value of code 127
+	codes    []byte
+}
+
+func quantize(method string, embedding []float32) *quantizedVector {
+	embedding = normalized(embedding)
+	vector := &quantizedVector{dims: len(embedding), binary: method == QuantizationBinary}
+
+	switch method {
+	case QuantizationInt8:
+		for _, value := range embedding {
+			vector.scale = max(vector.scale, float32(math.Abs(float64(value))))
+		}
+
+		vector.codes = make([]byte, len(embedding))
+		for i, value := range embedding {
+			if vector.scale > 0 {
+				vector.codes[i] = byte(int8(math.Round(float64(value / vector.scale * 127))))
+			}
+		}
+	case QuantizationBinary:
+		vector.codes = make([]byte, (len(embedding)+7)/8)
+		for i, value := range embedding {
+			if value > 0 {
+				vector.codes[i/8] |= 1 << (i % 8)
+			}
+		}
+	}
+
+	return vector
+}
+
+// similarity approximates the cosine similarity to a normalized query, which
+// is kept at full precision
+func (v *quantizedVector) similarity(query []float32) float32 {
+	if len(query) != v.dims {
+		return 0
+	}
+
+	var sum float32
+	if v.binary {
+		// Every dimension is ±1/sqrt(dims)
+		for i, value := range query {
+			if v.codes[i/8]&(1<<(i%8)) != 0 {
+				sum += value
+			} else {
+				sum -= value
+			}
+		}
+		return sum / float32(math.Sqrt(float64(v.dims)))
+	}
+
+	for i, value := range query {
+		sum += value * float32(int8(v.codes[i]))
+	}
+	return sum * v.scale / 127
+}
+
+// embedding restores an approximation of the normalized embedding
+func (v *quantizedVector) embedding() []float32 {
+	embedding := make([]float32, v.dims)
+	if v.binary {
+		value := float32(1 / math.Sqrt(float64(v.dims)))
+		for i := range embedding {
+			if v.codes[i/8]&(1<<(i%8)) != 0 {
+				embedding[i] = value
+			} else {
+				embedding[i] = -value
+			}
+		}
+		return embedding
+	}
+
+	for i, code := range v.codes {
+		embedding[i] = float32(int8(code)) * v.scale / 127
+	}
+	return embedding
+}
+
+// encode packs the vector as base64 for the document metadata
+func (v *quantizedVector) encode() string {
+	data := make([]byte, 8, 8+len(v.codes))
+	binary.LittleEndian.PutUint32(data, uint32(v.dims))
+	binary.LittleEndian.PutUint32(data[4:], math.Float32bits(v.scale))
+	data = append(data, v.codes...)
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func decodeQuantized(method string, encoded string) (*quantizedVector, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < 8 {
+		return nil, fmt.Errorf("invalid quantized embedding")
+	}
+
+	return &quantizedVector{
+		dims:   int(binary.LittleEndian.Uint32(data)),
+		binary: method == QuantizationBinary,
+		scale:  math.Float32frombits(binary.LittleEndian.Uint32(data[4:])),
+		codes:  data[8:],
+	}, nil
+}
+
+// quantizedStore keeps the embeddings of the wrapped store quantized, in the
+// document metadata, and searches them in memory. Full-precision embeddings
+// are only read from the on-disk embedding cache to rescore the top candidates.
+type quantizedStore struct {
+	VectorStore
+	method     string
+	rescore    int
+	embeddings *embeddingCache // full-precision embeddings
+
+	vectors map[string]*quantizedVector // document ID -> quantized embedding
+	changes atomic.Int64                // bumped on every write, recall is re-measured after changes
+	mu      sync.RWMutex
+}
+
+func newQuantizedStore(
+	ctx context.Context,
+	store VectorStore,
+	cfg QuantizationConfig,
+	embeddings *embeddingCache,
+) (*quantizedStore, error) {
+	docs, err := store.List(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make(map[string]*quantizedVector, len(docs))
+	for _, doc := range docs {
+		vector, err := decodeQuantized(cfg.Method, doc.Metadata[quantizedKey])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load quantized embedding of %s: %w", doc.ID, err)
+		}
+
+		vector.file = doc.Metadata["file"]
+		vector.language = doc.Metadata["language"]
+		vector.key = embeddings.documentKey(*doc)
+		vectors[doc.ID] = vector
+	}
+
+	return &quantizedStore{
+		VectorStore: store,
+		method:      cfg.Method,
+		rescore:     cfg.Rescore,
+		embeddings:  embeddings,
+		vectors:     vectors,
+	}, nil
+}
+
+func (s *quantizedStore) Add(ctx context.Context, docs []chromem.Document) error {
+	return s.Replace(ctx, nil, docs)
+}
+
+func (s *quantizedStore) Replace(ctx context.Context, ids []string, docs []chromem.Document) error {
+	stored := make([]chromem.Document, 0, len(docs))
+	vectors := make(map[string]*quantizedVector, len(docs))
+	for _, doc := range docs {
+		vector := quantize(s.method, doc.Embedding)
+		vector.file = doc.Metadata["file"]
+		vector.language = doc.Metadata["language"]
+		vector.key = s.embeddings.documentKey(doc)
+		vectors[doc.ID] = vector
+
+		metadata := maps.Clone(doc.Metadata)
+		metadata[quantizedKey] = vector.encode()
+
+		// The document is only searched through its quantized embedding
+		stored = append(stored, chromem.Document{
+			ID:        doc.ID,
+			Metadata:  metadata,
+			Embedding: []float32{1},
+			Content:   doc.Content,
+		})
+	}
+
+	err := s.VectorStore.Replace(ctx, ids, stored)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.vectors, id)
+	}
+	maps.Copy(s.vectors, vectors)
+	s.changes.Add(1)
+	return nil
+}
+
+func (s *quantizedStore) Delete(ctx context.Context, ids ...string) error {
+	err := s.VectorStore.Delete(ctx, ids...)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.vectors, id)
+	}
+	s.changes.Add(1)
+	return nil
+}
+
+func (s *quantizedStore) DeleteFile(ctx context.Context, filePath string) error {
+	err := s.VectorStore.DeleteFile(ctx, filePath)
+	if err != nil {
+		return err
+	}
+
+	s.deleteVectors(func(vector *quantizedVector) bool { return vector.file == filePath })
+	return nil
+}
+
+func (s *quantizedStore) DeleteLanguage(ctx context.Context, language string) error {
+	err := s.VectorStore.DeleteLanguage(ctx, language)
+	if err != nil {
+		return err
+	}
+
+	s.deleteVectors(func(vector *quantizedVector) bool { return vector.language == language })
+	return nil
+}
+
+func (s *quantizedStore) Clear(ctx context.Context) error {
+	err := s.VectorStore.Clear(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.deleteVectors(func(*quantizedVector) bool { return true })
+	return nil
+}
+
+func (s *quantizedStore) deleteVectors(matches func(vector *quantizedVector) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maps.DeleteFunc(s.vectors, func(_ string, vector *quantizedVector) bool { return matches(vector) })
+	s.changes.Add(1)
+}
+
+// Get returns the document with its full-precision embedding when it's cached,
+// or the quantized approximation otherwise
+func (s *quantizedStore) Get(ctx context.Context, id string) (chromem.Document, error) {
+	doc, err := s.VectorStore.Get(ctx, id)
+	if err != nil {
+		return chromem.Document{}, err
+	}
+
+	doc.Embedding = s.embedding(doc.ID)
+	return doc, nil
+}
+
+func (s *quantizedStore) List(ctx context.Context, embeddings bool) ([]*chromem.Document, error) {
+	docs, err := s.VectorStore.List(ctx, false)
+	if err != nil || !embeddings {
+		return docs, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, doc := range docs {
+		if vector, exists := s.vectors[doc.ID]; exists {
+			doc.Embedding = vector.embedding()
+		}
+	}
+
+	return docs, nil
+}
+
+// Query ranks the quantized embeddings, then rescores the top candidates with
+// their full-precision embeddings
+func (s *quantizedStore) Query(ctx context.Context, embedding []float32, n int) ([]chromem.Result, error) {
+	query := normalized(embedding)
+
+	s.mu.RLock()
+	results := make([]chromem.Result, 0, len(s.vectors))
+	keys := make(map[string]string, len(s.vectors)) // document ID -> cache key
+	for id, vector := range s.vectors {
+		results = append(results, chromem.Result{ID: id, Similarity: vector.similarity(query)})
+		keys[id] = vector.key
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(results, byResultSimilarity)
+
+	if s.rescore > 0 {
+		// Every candidate is read from disk, so filtered searches asking for all
+		// results only rescore the top ones, the rest keep their quantized similarity
+		candidates := results[:min(len(results), min(n, maxRescored)*s.rescore)]
+		for i, candidate := range candidates {
+			if full, found := s.embeddings.get(keys[candidate.ID]); found {
+				candidates[i].Similarity = dot(query, normalized(full))
+			}
+		}
+
+		slices.SortFunc(candidates, byResultSimilarity)
+	}
+
+	if len(results) > n {
+		results = results[:n]
+	}
+
+	return results, nil
+}
+
+// embedding returns the full-precision embedding of a document when it's
+// cached, or the quantized approximation otherwise
+func (s *quantizedStore) embedding(id string) []float32 {
+	s.mu.RLock()
+	vector, exists := s.vectors[id]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil
+	}
+
+	if full, found := s.embeddings.get(vector.key); found {
+		return full
+	}
+	return vector.embedding()
+}
+
+// measureRecall compares quantized searches for sampled chunks against exact
+// full-precision searches, returning the number of top recallK results found
+// & expected. Chunks without a cached full-precision embedding are left out.
+func (s *quantizedStore) measureRecall(ctx context.Context) (int, int, error) {
+	s.mu.RLock()
+	keys := make(map[string]string, len(s.vectors)) // document ID -> cache key
+	for id, vector := range s.vectors {
+		keys[id] = vector.key
+	}
+	s.mu.RUnlock()
+
+	// Sample the same chunks on every run, so measurements are comparable
+	ids := slices.Sorted(maps.Keys(keys))
+	rng := rand.New(rand.NewSource(1))
+	rng.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+
+	var queries [][]float32
+	for _, id := range ids {
+		if len(queries) == recallSamples {
+			break
+		}
+		if full, found := s.embeddings.get(keys[id]); found {
+			queries = append(queries, normalized(full))
+		}
+	}
+
+	if len(ids) <= recallK || len(queries) == 0 {
+		return 0, 0, nil
+	}
+
+	// Stream the full-precision embeddings, keeping the exact top matches of each query
+	exact := make([][]chromem.Result, len(queries))
+	for _, id := range ids {
+		full, found := s.embeddings.get(keys[id])
+		if !found {
+			continue
+		}
+		full = normalized(full)
+
+		for i, query := range queries {
+			exact[i] = insertTop(exact[i], chromem.Result{ID: id, Similarity: dot(query, full)}, recallK)
+		}
+	}
+
+	var hits, total int
+	for i, query := range queries {
+		approximate, err := s.Query(ctx, query, recallK)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		found := map[string]bool{}
+		for _, result := range approximate {
+			found[result.ID] = true
+		}
+
+		for _, result := range exact[i] {
+			if found[result.ID] {
+				hits++
+			}
+		}
+		total += len(exact[i])
+	}
+
+	return hits, total, nil
+}
+
+// byResultSimilarity orders results by descending similarity, quantized
+// similarities often tie so ties are ordered by ID to keep rankings stable
+func byResultSimilarity(a, b chromem.Result) int {
+	return cmp.Or(cmp.Compare(b.Similarity, a.Similarity), cmp.Compare(a.ID, b.ID))
+}
+
+// insertTop adds a result to a list of the k most similar results
+func insertTop(top []chromem.Result, result chromem.Result, k int) []chromem.Result {
+	i, _ := slices.BinarySearchFunc(top, result, byResultSimilarity)
+	if i >= k {
+		return top
+	}
+
+	top = slices.Insert(top, i, result)
+	if len(top) > k {
+		top = top[:k]
+	}
+	return top
+}
+
+// recallMeasurement is the last measured recall of quantized searches
+type recallMeasurement struct {
+	recall    float64
+	ok        bool // some chunks could be compared at full precision
+	measured  bool
+	running   bool
+	changesAt int64 // store changes when it was measured
+	mu        sync.Mutex
+}
+
+// QuantizationRecall returns the recall@10 of quantized searches against exact
+// full-precision searches. It's measured in the background when the index
+// changed since the last measurement, ok is false until the first one is done
+// or when embeddings aren't quantized.
+func (idx *Index) QuantizationRecall() (recall float64, ok bool) {
+	var stores []*quantizedStore
+	var changes int64
+	for _, store := range idx.stores {
+		if quantized, isQuantized := store.(*quantizedStore); isQuantized {
+			stores = append(stores, quantized)
+			changes += quantized.changes.Load()
+		}
+	}
+
+	if len(stores) == 0 {
+		return 0, false
+	}
+
+	m := &idx.quantizationRecall
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.running && (!m.measured || m.changesAt != changes) {
+		m.running = true
+		go func() {
+			var hits, total int
+			for _, store := range stores {
+				storeHits, storeTotal, err := store.measureRecall(context.Background())
+				if err != nil {
+					log.Printf("Failed to measure quantization recall: %v", err)
+				}
+				hits += storeHits
+				total += storeTotal
+			}
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			m.running = false
+			m.measured = true
+			m.changesAt = changes
+			m.ok = total > 0
+			if m.ok {
+				m.recall = float64(hits) / float64(total)
+				log.Printf("Quantized search recall@%d: %.3f", recallK, m.recall)
+			}
+		}()
+	}
+
+	return m.recall, m.ok
+}
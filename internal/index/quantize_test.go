@@ -0,0 +1,237 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+// randomEmbeddingServer serves random unit embeddings seeded by the text over
+// the Ollama API, of the size hosted models return
+func randomEmbeddingServer(t testing.TB, dims int) EmbedderConfig {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input string `json:"input"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rng := rand.New(rand.NewSource(int64(xxhash.Sum64String(body.Input))))
+		embedding := make([]float32, dims)
+		for i := range embedding {
+			embedding[i] = float32(rng.NormFloat64())
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": [][]float32{normalized(embedding)}})
+	}))
+	t.Cleanup(server.Close)
+
+	return EmbedderConfig{Provider: ProviderOllama, BaseURL: server.URL}
+}
+
+// dirSize sums the sizes of the files under a dir
+func dirSize(t testing.TB, dir string) int64 {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return size
+}
+
+// heapAlloc returns the bytes of live heap objects
+func heapAlloc() uint64 {
+	runtime.GC()
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// BenchmarkQuantizedIndexSize opens an index of 10,000 chunks of about 800
+// bytes with 1536-dimension embeddings, reporting the heap it holds, the size
+// of its chunk store & of its embedding cache, in MB
+func BenchmarkQuantizedIndexSize(b *testing.B) {
+	const (
+		nFiles         = 1000
+		chunksPerFile  = 10
+		dims           = 1536
+		bytesPerMB     = 1e6
+		chunkBodyLines = 25
+	)
+
+	body := strings.Repeat("\tresult := compute(input, options) // a line of code\n", chunkBodyLines)
+
+	for _, method := range []string{QuantizationNone, QuantizationInt8, QuantizationBinary} {
+		b.Run(method, func(b *testing.B) {
+			root := b.TempDir()
+			cfg := Config{
+				Embedder:     randomEmbeddingServer(b, dims),
+				Quantization: QuantizationConfig{Method: method, Rescore: defaultRescore},
+			}
+
+			idx := openIndex(b, root, cfg)
+			for i := range nFiles {
+				chunks := make([]testChunk, 0, chunksPerFile)
+				for j := range chunksPerFile {
+					chunks = append(chunks, testChunk{
+						path:   fmt.Sprintf("Func%d", j),
+						kind:   parser.KindFunction,
+						source: fmt.Sprintf("func Func%d() {\n%s}", j, body),
+					})
+				}
+				indexFiles(b, idx, writeFile(b, root, fmt.Sprintf("pkg/file%d.go", i), "go", chunks...))
+			}
+			closeIndex(b, idx)
+
+			var heap uint64
+			for b.Loop() {
+				before := heapAlloc()
+				idx := openIndex(b, root, cfg)
+				heap = heapAlloc() - before
+
+				closeIndex(b, idx)
+			}
+
+			dbPath := filepath.Join(root, workspaceDBDir)
+			cacheSize := dirSize(b, filepath.Join(dbPath, embeddingCacheDir))
+
+			b.ReportMetric(float64(heap)/bytesPerMB, "heap-MB")
+			b.ReportMetric(float64(dirSize(b, dbPath)-cacheSize)/bytesPerMB, "store-MB")
+			b.ReportMetric(float64(cacheSize)/bytesPerMB, "cache-MB")
+		})
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	embedding := make([]float32, 100)
+	for i := range embedding {
+		embedding[i] = float32(rng.NormFloat64())
+	}
+	embedding = normalized(embedding)
+
+	tests := []struct {
+		method        string
+		size          int     // bytes of codes
+		minSimilarity float32 // to the original embedding
+	}{
+		{QuantizationInt8, 100, 0.999},
+		{QuantizationBinary, 13, 0.7},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			vector := quantize(test.method, embedding)
+			if len(vector.codes) != test.size {
+				t.Errorf("%d bytes of codes, expected %d", len(vector.codes), test.size)
+			}
+
+			similarity := vector.similarity(embedding)
+			if similarity < test.minSimilarity || similarity > 1.001 {
+				t.Errorf("similarity %.4f to the original, expected at least %.4f", similarity, test.minSimilarity)
+			}
+
+			restored := cosineSimilarity(vector.embedding(), embedding)
+			if math.Abs(float64(restored-similarity)) > 1e-4 {
+				t.Errorf("restored embedding similarity %.4f, expected %.4f", restored, similarity)
+			}
+
+			decoded, err := decodeQuantized(test.method, vector.encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.similarity(embedding) != similarity {
+				t.Errorf("decoded similarity %.4f, expected %.4f", decoded.similarity(embedding), similarity)
+			}
+		})
+	}
+}
+
+func TestQuantizedRescoring(t *testing.T) {
+	const (
+		nFiles = 200
+		dims   = 64
+	)
+
+	ctx := context.Background()
+	for _, test := range []struct {
+		method    string
+		rescore   int
+		exact     bool    // whether top similarities are full precision
+		minRecall float64 // of quantized against exact searches
+	}{
+		{QuantizationInt8, defaultRescore, true, 0.95},
+		{QuantizationBinary, defaultRescore, true, 0.8},
+		{QuantizationBinary, 0, false, 0},
+	} {
+		t.Run(fmt.Sprintf("%s rescore %d", test.method, test.rescore), func(t *testing.T) {
+			root := t.TempDir()
+			cfg := Config{
+				Embedder:     randomEmbeddingServer(t, dims),
+				Quantization: QuantizationConfig{Method: test.method, Rescore: test.rescore},
+			}
+
+			idx := openIndex(t, root, cfg)
+			defer closeIndex(t, idx)
+
+			for i := range nFiles {
+				indexFiles(t, idx, writeFile(t, root, fmt.Sprintf("file%d.go", i), "go", funcChunk(fmt.Sprintf("Func%d", i), "return nil")))
+			}
+
+			store := idx.stores[string(parser.FileTypeSrc)].(*quantizedStore)
+			for i := range 10 {
+				id := fmt.Sprintf("file%d.go::Func%d", i, i)
+				query := store.embedding(id)
+
+				results, err := store.Query(ctx, query, 5)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// A chunk's own embedding finds it first, scored at full precision when rescored
+				if len(results) != 5 || results[0].ID != id {
+					t.Fatalf("query for %s found %v", id, results)
+				}
+				if exact := math.Abs(float64(results[0].Similarity)-1) < 1e-4; exact != test.exact {
+					t.Errorf("top similarity %.5f, full precision: %v", results[0].Similarity, test.exact)
+				}
+			}
+
+			hits, total, err := store.measureRecall(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total == 0 || float64(hits)/float64(total) < test.minRecall {
+				t.Errorf("recall %d/%d, expected at least %.2f", hits, total, test.minRecall)
+			}
+		})
+	}
+}
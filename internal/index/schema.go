@@ -0,0 +1,170 @@
+package index
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+const (
+	// schemaVersion is bumped whenever the layout of stored chunk documents
+	// changes, so indexes written by older versions are rebuilt
+	schemaVersion = 3
+
+	schemaCollection = "index-schema"
+	schemaDocID      = "schema"
+	parserKeyPrefix  = "parser:"
+)
+
+// schema describes how the stored chunks were produced
+type schema struct {
+	Version      int
+	Model        string            // embedding model id
+	Quantization string            // how embeddings are compressed
+	Parsers      map[string]string // language -> parser spec fingerprint
+}
+
+func newSchema(embedder Embedder, quantization string, parsers map[string]string) *schema {
+	return &schema{
+		Version:      schemaVersion,
+		Model:        embedder.Model(),
+		Quantization: quantization,
+		Parsers:      parsers,
+	}
+}
+
+// loadSchema reads the schema the index was written with, or nil if none was recorded
+func loadSchema(ctx context.Context, collection *chromem.Collection) *schema {
+	doc, err := collection.GetByID(ctx, schemaDocID)
+	if err != nil {
+		return nil
+	}
+
+	version, _ := strconv.Atoi(doc.Metadata["version"])
+	stored := &schema{
+		Version:      version,
+		Model:        doc.Metadata["model"],
+		Quantization: cmp.Or(doc.Metadata["quantization"], QuantizationNone), // recorded since quantization was added
+		Parsers:      map[string]string{},
+	}
+	for key, value := range doc.Metadata {
+		if lang, found := strings.CutPrefix(key, parserKeyPrefix); found {
+			stored.Parsers[lang] = value
+		}
+	}
+
+	return stored
+}
+
+// save records the schema in the collection
+func (s *schema) save(ctx context.Context, collection *chromem.Collection) error {
+	metadata := map[string]string{
+		"version":      strconv.Itoa(s.Version),
+		"model":        s.Model,
+		"quantization": s.Quantization,
+	}
+	for lang, fingerprint := range s.Parsers {
+		metadata[parserKeyPrefix+lang] = fingerprint
+	}
+
+	// The document is only looked up by ID, its embedding is a placeholder
+	err := collection.AddDocument(ctx, chromem.Document{
+		ID:        schemaDocID,
+		Metadata:  metadata,
+		Embedding: []float32{1},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save index schema: %w", err)
+	}
+
+	return nil
+}
+
+// invalidates returns why chunks stored with the old schema can't be reused at
+// all, or an empty string if they can
+func (s *schema) invalidates(old *schema) string {
+	switch {
+	case old.Version != s.Version:
+		return fmt.Sprintf("schema version changed from %d to %d", old.Version, s.Version)
+	case old.Model != s.Model:
+		return fmt.Sprintf("embedding model changed from %s to %s", old.Model, s.Model)
+	case old.Quantization != s.Quantization:
+		return fmt.Sprintf("embedding quantization changed from %s to %s", old.Quantization, s.Quantization)
+	default:
+		return ""
+	}
+}
+
+// changedLanguages returns the languages whose parser spec changed since the old
+// schema, their chunks have to be re-extracted
+func (s *schema) changedLanguages(old *schema) []string {
+	var changed []string
+	for lang := range old.Parsers {
+		if s.Parsers[lang] != old.Parsers[lang] {
+			changed = append(changed, lang)
+		}
+	}
+
+	slices.Sort(changed)
+	return changed
+}
+
+// migrate drops the chunks that are incompatible with the current schema, so the
+// workspace scan re-indexes their files, and records the current schema. It
+// returns whether the chunks of a language were dropped.
+func migrate(
+	ctx context.Context,
+	db *chromem.DB,
+	stores map[string]VectorStore,
+	current *schema,
+) (func(language string) bool, error) {
+	dropped := func(string) bool { return false }
+
+	collection, err := db.GetOrCreateCollection(schemaCollection, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index schema collection: %w", err)
+	}
+
+	var chunks int
+	for _, store := range stores {
+		chunks += store.Count()
+	}
+
+	old := loadSchema(ctx, collection)
+	if old == nil && chunks > 0 {
+		// Written before schemas were recorded
+		old = &schema{Quantization: QuantizationNone, Parsers: map[string]string{}}
+	}
+
+	if old != nil {
+		if reason := current.invalidates(old); reason != "" {
+			log.Printf("Rebuilding index, %s", reason)
+			for _, store := range stores {
+				err = store.Clear(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("failed to drop chunks: %w", err)
+				}
+			}
+			dropped = func(string) bool { return true }
+		} else if changed := current.changedLanguages(old); len(changed) > 0 {
+			log.Printf("Re-indexing %s files, their parser changed", strings.Join(changed, ", "))
+			for _, store := range stores {
+				for _, lang := range changed {
+					err = store.DeleteLanguage(ctx, lang)
+					if err != nil {
+						return nil, fmt.Errorf("failed to remove documents from vector db: %w", err)
+					}
+				}
+			}
+			dropped = func(language string) bool { return slices.Contains(changed, language) }
+		}
+	}
+
+	return dropped, current.save(ctx, collection)
+}
@@ -0,0 +1,105 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+func TestSchemaMigration(t *testing.T) {
+	tests := []struct {
+		name     string
+		reconfig func(cfg *Config)
+		stale    []string // files to re-index after reopening
+	}{
+		{
+			name:     "unchanged",
+			reconfig: func(cfg *Config) {},
+			stale:    []string{},
+		},
+		{
+			name:     "model",
+			reconfig: func(cfg *Config) { cfg.Embedder.Model = "other-model" },
+			stale:    []string{"a.go", "b.py"},
+		},
+		{
+			name:     "quantization",
+			reconfig: func(cfg *Config) { cfg.Quantization.Method = QuantizationBinary },
+			stale:    []string{"a.go", "b.py"},
+		},
+		{
+			name:     "parser of a language",
+			reconfig: func(cfg *Config) { cfg.Parsers = map[string]string{"go": "v2", "python": "v1"} },
+			stale:    []string{"a.go"},
+		},
+		{
+			name:     "parser of a new language",
+			reconfig: func(cfg *Config) { cfg.Parsers["javascript"] = "v1" },
+			stale:    []string{},
+		},
+	}
+
+	for _, test := range tests {
+		for _, manifest := range []bool{true, false} {
+			name := test.name
+			if !manifest {
+				name += " without manifest"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				root := t.TempDir()
+				embedder, _ := embeddingServer(t)
+				cfg := Config{Embedder: embedder, Parsers: map[string]string{"go": "v1", "python": "v1"}}
+
+				idx := openIndex(t, root, cfg)
+				indexFiles(t, idx,
+					writeFile(t, root, "a.go", "go", funcChunk("openStore", "return nil")),
+					writeFile(t, root, "b.py", "python", testChunk{path: "load", kind: parser.KindFunction, source: "def load():\n    pass"}),
+				)
+				closeIndex(t, idx)
+
+				if !manifest {
+					err := os.Remove(filepath.Join(root, workspaceDBDir, manifestFile))
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				test.reconfig(&cfg)
+				idx = openIndex(t, root, cfg)
+				defer func() { closeIndex(t, idx) }()
+
+				stale := []string{}
+				for _, filePath := range []string{"a.go", "b.py"} {
+					if idx.IsStale(filePath) {
+						stale = append(stale, filePath)
+					}
+				}
+				if !slices.Equal(stale, test.stale) {
+					t.Errorf("stale files %v, expected %v", stale, test.stale)
+				}
+
+				var chunks int
+				for _, store := range idx.stores {
+					chunks += store.Count()
+				}
+				if chunks != 2-len(test.stale) {
+					t.Errorf("%d chunks kept, expected %d", chunks, 2-len(test.stale))
+				}
+
+				// The new schema is recorded, so reopening doesn't drop chunks again
+				closeIndex(t, idx)
+				idx = openIndex(t, root, cfg)
+				for _, store := range idx.stores {
+					chunks -= store.Count()
+				}
+				if chunks != 0 {
+					t.Errorf("%d chunks dropped by reopening", chunks)
+				}
+			})
+		}
+	}
+}
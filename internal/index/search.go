@@ -0,0 +1,219 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+const (
+	// Defaults for Search
+	DefaultLimit    = 30
+	DefaultMinScore = 0.3
+
+	// Defaults for FindSimilarChunks, which only surfaces close matches
+	DefaultSimilarLimit    = 10
+	DefaultSimilarMinScore = 0.6
+
+	// MaxLimit caps the results per page
+	MaxLimit = 100
+
+	// searchPool is the number of candidates each retriever contributes to
+	// Search & the number of fused results it pages through. It doesn't depend
+	// on the offset, so chunks keep their rank from page to page
+	searchPool = 2 * MaxLimit
+)
+
+// SearchOptions narrows and pages search results
+type SearchOptions struct {
+	FileTypes    []string // file types to include, defaults to src & docs
+	Languages    []string // languages to include, e.g. go or python
+	Kinds        []string // chunk kinds to include, e.g. function or type
+	IncludePaths []string // glob patterns, only files matching one are included
+	ExcludePaths []string // glob patterns, files matching one are excluded
+	Limit        int      // max results per page
+	MinScore     float32  // min cosine similarity of semantic matches
+	Offset       int      // number of results to skip, for paging
+	Diversity    float32  // between 0, ranking by relevance only, and 1, favouring results unlike those ranked above
+	MaxPerFile   int      // max results from one file, 0 for no cap
+}
+
+// SearchResult is a chunk matched by a search
+type SearchResult struct {
+	ID          string  `json:"id"`
+	Repo        string  `json:"repo,omitempty"` // alias of the repository, when searching several
+	File        string  `json:"file"`
+	Language    string  `json:"language"`
+	Path        string  `json:"path"`
+	Type        string  `json:"type"`
+	Kind        string  `json:"kind"`
+	Summary     string  `json:"summary"`
+	StartLine   uint    `json:"start_line"`
+	StartColumn uint    `json:"start_column"`
+	EndLine     uint    `json:"end_line"`
+	EndColumn   uint    `json:"end_column"`
+	Score       float32 `json:"score"` // cosine similarity to the query
+}
+
+// SearchPage is a page of search results
+type SearchPage struct {
+	Results    []*SearchResult `json:"results"`
+	NextOffset int             `json:"next_offset,omitempty"` // 0 when there are no more results
+}
+
+func newSearchResult(chunk *parser.Chunk, score float32) *SearchResult {
+	return &SearchResult{
+		ID:          chunk.ID(),
+		File:        chunk.File,
+		Language:    chunk.Language,
+		Path:        chunk.Path,
+		Type:        chunk.Type,
+		Kind:        string(chunk.Kind),
+		Summary:     chunk.Summary,
+		StartLine:   chunk.StartLine,
+		StartColumn: chunk.StartColumn,
+		EndLine:     chunk.EndLine,
+		EndColumn:   chunk.EndColumn,
+		Score:       score,
+	}
+}
+
+// String renders the result as "id | summary [lines]"
+func (r *SearchResult) String() string {
+	var lines string
+	if r.StartLine == r.EndLine {
+		lines = fmt.Sprintf("line %d", r.StartLine)
+	} else {
+		lines = fmt.Sprintf("lines %d-%d", r.StartLine, r.EndLine)
+	}
+
+	return fmt.Sprintf("%s | %s [%s]", r.ID, r.Summary, lines)
+}
+
+// DefaultSearchOptions returns the options Search uses when none are given
+func DefaultSearchOptions() SearchOptions {
+	return SearchOptions{
+		FileTypes: []string{"src", "docs"},
+		Limit:     DefaultLimit,
+		MinScore:  DefaultMinScore,
+	}
+}
+
+// DefaultSimilarOptions returns the options FindSimilarChunks uses when none are given
+func DefaultSimilarOptions() SearchOptions {
+	return SearchOptions{
+		Limit:    DefaultSimilarLimit,
+		MinScore: DefaultSimilarMinScore,
+	}
+}
+
+// window returns the number of ranked candidates needed to fill the requested
+// page, plus one to tell whether another page follows
+func (o SearchOptions) window() int {
+	return o.Offset + o.Limit + 1
+}
+
+// validate checks the diversity is in range & the path patterns are well-formed
+func (o SearchOptions) validate() error {
+	if o.Diversity < 0 || o.Diversity > 1 {
+		return fmt.Errorf("diversity must be between 0 and 1")
+	}
+
+	for _, pattern := range append(slices.Clone(o.IncludePaths), o.ExcludePaths...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("invalid path pattern: %s", pattern)
+		}
+	}
+
+	return nil
+}
+
+// filtersNarrowly reports whether results are filtered by path, language or kind,
+// in which case similarity searches can't rely on over-fetching a few extra candidates
+func (o SearchOptions) filtersNarrowly() bool {
+	return len(o.IncludePaths) > 0 || len(o.ExcludePaths) > 0 || len(o.Languages) > 0 || len(o.Kinds) > 0
+}
+
+// accepts reports whether a chunk passes the type, language, kind & path filters
+func (o SearchOptions) accepts(chunk *parser.Chunk) bool {
+	if len(o.FileTypes) > 0 && !slices.Contains(o.FileTypes, chunk.Type) {
+		return false
+	}
+
+	if len(o.Languages) > 0 && !slices.Contains(o.Languages, chunk.Language) {
+		return false
+	}
+
+	if len(o.Kinds) > 0 && !slices.Contains(o.Kinds, string(chunk.Kind)) {
+		return false
+	}
+
+	if len(o.IncludePaths) > 0 && !matchesAny(o.IncludePaths, chunk.File) {
+		return false
+	}
+
+	return !matchesAny(o.ExcludePaths, chunk.File)
+}
+
+// matchesAny reports whether the file path matches any of the glob patterns
+func matchesAny(patterns []string, filePath string) bool {
+	for _, pattern := range patterns {
+		matched, _ := doublestar.Match(pattern, filePath)
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalize clamps the paging options to sane values
+func (o SearchOptions) normalize(defaultLimit int) SearchOptions {
+	if o.Limit <= 0 {
+		o.Limit = defaultLimit
+	}
+	o.Limit = min(o.Limit, MaxLimit)
+	o.Offset = max(o.Offset, 0)
+	o.MaxPerFile = max(o.MaxPerFile, 0)
+
+	return o
+}
+
+// paginate cuts the requested page out of ranked results
+func paginate(results []*SearchResult, opts SearchOptions) *SearchPage {
+	page := &SearchPage{Results: []*SearchResult{}}
+	if opts.Offset >= len(results) {
+		return page
+	}
+
+	end := min(opts.Offset+opts.Limit, len(results))
+	page.Results = results[opts.Offset:end]
+	if end < len(results) {
+		page.NextOffset = end
+	}
+
+	return page
+}
+
+// cosineSimilarity of two vectors, or 0 if they can't be compared
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return float32(dot / math.Sqrt(normA*normB))
+}
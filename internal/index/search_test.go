@@ -0,0 +1,271 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+func TestPaginate(t *testing.T) {
+	results := make([]*SearchResult, 5)
+	for i := range results {
+		results[i] = &SearchResult{ID: fmt.Sprint(i)}
+	}
+
+	tests := []struct {
+		name       string
+		opts       SearchOptions
+		ids        []string
+		nextOffset int
+	}{
+		{
+			name:       "first page",
+			opts:       SearchOptions{Limit: 2},
+			ids:        []string{"0", "1"},
+			nextOffset: 2,
+		},
+		{
+			name:       "middle page",
+			opts:       SearchOptions{Limit: 2, Offset: 2},
+			ids:        []string{"2", "3"},
+			nextOffset: 4,
+		},
+		{
+			name: "last page",
+			opts: SearchOptions{Limit: 2, Offset: 4},
+			ids:  []string{"4"},
+		},
+		{
+			name: "exactly filled",
+			opts: SearchOptions{Limit: 5},
+			ids:  []string{"0", "1", "2", "3", "4"},
+		},
+		{
+			name: "past the end",
+			opts: SearchOptions{Limit: 2, Offset: 7},
+			ids:  []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := paginate(results, test.opts)
+
+			ids := []string{}
+			for _, result := range page.Results {
+				ids = append(ids, result.ID)
+			}
+
+			if !slices.Equal(ids, test.ids) || page.NextOffset != test.nextOffset {
+				t.Errorf("page %v next %d, expected %v next %d", ids, page.NextOffset, test.ids, test.nextOffset)
+			}
+		})
+	}
+}
+
+func TestNormalizeSearchOptions(t *testing.T) {
+	tests := []struct {
+		opts     SearchOptions
+		expected SearchOptions
+	}{
+		{SearchOptions{}, SearchOptions{Limit: DefaultLimit}},
+		{SearchOptions{Limit: 5, Offset: 10}, SearchOptions{Limit: 5, Offset: 10}},
+		{SearchOptions{Limit: MaxLimit + 1}, SearchOptions{Limit: MaxLimit}},
+		{SearchOptions{Limit: -1, Offset: -5, MaxPerFile: -1}, SearchOptions{Limit: DefaultLimit}},
+	}
+
+	for _, test := range tests {
+		opts := test.opts.normalize(DefaultLimit)
+		if opts.Limit != test.expected.Limit || opts.Offset != test.expected.Offset || opts.MaxPerFile != test.expected.MaxPerFile {
+			t.Errorf("normalized %+v to %+v, expected %+v", test.opts, opts, test.expected)
+		}
+	}
+}
+
+// searchIDs returns the IDs of a page's results
+func searchIDs(page *SearchPage) []string {
+	ids := []string{}
+	for _, result := range page.Results {
+		ids = append(ids, result.ID)
+	}
+
+	return ids
+}
+
+func TestSearchPages(t *testing.T) {
+	const nFiles = 25
+
+	root := t.TempDir()
+	idx := openIndex(t, root, offlineConfig())
+	defer closeIndex(t, idx)
+
+	for i := range nFiles {
+		name := fmt.Sprintf("openStore%d", i)
+		indexFiles(t, idx, writeFile(t, root, fmt.Sprintf("store%d.go", i), "go", funcChunk(name, "return openDB(dir)")))
+	}
+
+	ctx := context.Background()
+	all, err := idx.Search(ctx, "open store", SearchOptions{Limit: nFiles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Results) != nFiles || all.NextOffset != 0 {
+		t.Fatalf("found %d results, next offset %d, expected %d & none", len(all.Results), all.NextOffset, nFiles)
+	}
+
+	// Pages follow each other without gaps or repeats
+	var paged []string
+	for _, expected := range []struct{ offset, n, next int }{{0, 10, 10}, {10, 10, 20}, {20, 5, 0}} {
+		page, err := idx.Search(ctx, "open store", SearchOptions{Limit: 10, Offset: expected.offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(page.Results) != expected.n || page.NextOffset != expected.next {
+			t.Errorf("page at %d has %d results, next offset %d, expected %d & %d",
+				expected.offset, len(page.Results), page.NextOffset, expected.n, expected.next)
+		}
+		paged = append(paged, searchIDs(page)...)
+	}
+
+	if !slices.Equal(paged, searchIDs(all)) {
+		t.Errorf("pages hold %v, expected %v", paged, searchIDs(all))
+	}
+}
+
+func TestSearchMinScore(t *testing.T) {
+	root := t.TempDir()
+	idx := openIndex(t, root, offlineConfig())
+	defer closeIndex(t, idx)
+
+	// A long chunk embeds far from a query naming one of its identifiers
+	body := strings.Repeat("total += weights[i] * values[i] // accumulate the weighted sum\n\t", 20)
+	indexFiles(t, idx,
+		writeFile(t, root, "distance.go", "go", funcChunk("levenshteinDistance", body+"return total")),
+		writeFile(t, root, "store.go", "go", funcChunk("openStore", "return openDB(dir)")),
+	)
+
+	tests := []struct {
+		name     string
+		query    string
+		minScore float32
+		ids      []string
+	}{
+		{
+			name:     "keyword hits ignore the min score",
+			query:    "levenshteinDistance",
+			minScore: 0.99,
+			ids:      []string{"distance.go::levenshteinDistance"},
+		},
+		{
+			name:     "semantic matches above the min score are kept",
+			query:    "opening stores",
+			minScore: 0.1,
+			ids:      []string{"store.go::openStore"},
+		},
+		{
+			name:     "semantic matches below the min score are dropped",
+			query:    "opening stores",
+			minScore: 0.99,
+			ids:      []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := idx.Search(context.Background(), test.query, SearchOptions{MinScore: test.minScore})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ids := searchIDs(page); !slices.Equal(ids, test.ids) {
+				t.Errorf("found %v, expected %v", ids, test.ids)
+			}
+		})
+	}
+}
+
+func TestSearchOptionsAccepts(t *testing.T) {
+	chunk := &parser.Chunk{File: "internal/index/search.go", Language: "go", Type: "src", Kind: parser.KindFunction}
+
+	tests := []struct {
+		name    string
+		opts    SearchOptions
+		accepts bool
+	}{
+		{"no filters", SearchOptions{}, true},
+		{"file type", SearchOptions{FileTypes: []string{"src"}}, true},
+		{"other file type", SearchOptions{FileTypes: []string{"docs", "tests"}}, false},
+		{"include", SearchOptions{IncludePaths: []string{"internal/**"}}, true},
+		{"include another dir", SearchOptions{IncludePaths: []string{"cmd/**", "*.go"}}, false},
+		{"exclude", SearchOptions{ExcludePaths: []string{"**/search.go"}}, false},
+		{"exclude overrides include", SearchOptions{IncludePaths: []string{"**/*.go"}, ExcludePaths: []string{"internal/index/*"}}, false},
+		{"exclude another dir", SearchOptions{ExcludePaths: []string{"vendor/**"}}, true},
+		{"language", SearchOptions{Languages: []string{"python", "go"}}, true},
+		{"other language", SearchOptions{Languages: []string{"python"}}, false},
+		{"kind", SearchOptions{Kinds: []string{"method", "function"}}, true},
+		{"other kind", SearchOptions{Kinds: []string{"type"}}, false},
+	}
+
+	for _, test := range tests {
+		if accepts := test.opts.accepts(chunk); accepts != test.accepts {
+			t.Errorf("%s: accepts %v, expected %v", test.name, accepts, test.accepts)
+		}
+	}
+}
+
+func TestSearchOptionsValidate(t *testing.T) {
+	tests := []struct {
+		opts  SearchOptions
+		valid bool
+	}{
+		{SearchOptions{IncludePaths: []string{"**/*.go"}, ExcludePaths: []string{"vendor/**"}}, true},
+		{SearchOptions{IncludePaths: []string{"internal/[a-"}}, false},
+		{SearchOptions{ExcludePaths: []string{"{a,b"}}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.opts.validate(); (err == nil) != test.valid {
+			t.Errorf("validating %+v returned %v", test.opts, err)
+		}
+	}
+}
+
+func TestSearchFileTypes(t *testing.T) {
+	root := t.TempDir()
+	idx := openIndex(t, root, offlineConfig())
+	defer closeIndex(t, idx)
+
+	indexFiles(t, idx,
+		writeFile(t, root, "store.go", "go", funcChunk("openStore", "return openDB(dir)")),
+		writeFile(t, root, "store_test.go", "go", funcChunk("TestOpenStore", "openStore(t.TempDir())")),
+		writeFile(t, root, "README.md", "markdown", testChunk{path: "usage", kind: parser.KindOther, source: "Call openStore first"}),
+	)
+
+	tests := []struct {
+		fileTypes []string
+		ids       []string
+	}{
+		{nil, []string{"store.go::openStore", "README.md::usage"}},
+		{[]string{"tests"}, []string{"store_test.go::TestOpenStore"}},
+		{[]string{"docs", "tests"}, []string{"README.md::usage", "store_test.go::TestOpenStore"}},
+	}
+
+	for _, test := range tests {
+		page, err := idx.Search(context.Background(), "openStore", SearchOptions{FileTypes: test.fileTypes})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := searchIDs(page)
+		slices.Sort(ids)
+		slices.Sort(test.ids)
+		if !slices.Equal(ids, test.ids) {
+			t.Errorf("file types %v found %v, expected %v", test.fileTypes, ids, test.ids)
+		}
+	}
+}
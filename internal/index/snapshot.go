@@ -0,0 +1,300 @@
+package index
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+	"github.com/suvaidkhan/code-explore-mcp/internal/fs"
+)
+
+const (
+	snapshotManifestEntry = "snapshot.json"
+	snapshotIndexEntry    = "index.gob.gz"
+	// snapshotEmbeddingsDir holds an entry per cached embedding of the exported
+	// chunks, named after its cache key
+	snapshotEmbeddingsDir = "embeddings/"
+)
+
+// Snapshot describes an exported index
+type Snapshot struct {
+	SchemaVersion int               `json:"schema_version"`
+	Model         string            `json:"model"`                   // embedding model id
+	Quantization  string            `json:"quantization,omitempty"`  // how embeddings are compressed
+	Parsers       map[string]string `json:"parsers"`                 // language -> parser spec fingerprint
+	SourceCommit  string            `json:"source_commit,omitempty"` // commit the workspace was indexed at
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// Export writes the chunk documents, embeddings & metadata of the index to a tar
+// archive, along with a snapshot manifest & the cached full-precision
+// embeddings of the chunks
+func (idx *Index) Export(ctx context.Context, w io.Writer) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		SchemaVersion: idx.schema.Version,
+		Model:         idx.schema.Model,
+		Quantization:  idx.schema.Quantization,
+		Parsers:       idx.schema.Parsers,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	commit, err := fs.GitHead(idx.workspaceRoot)
+	if err != nil {
+		log.Printf("Exporting snapshot without source commit: %v", err)
+	} else {
+		snapshot.SourceCommit = commit
+		if dirty, _ := fs.GitIsDirty(idx.workspaceRoot); dirty {
+			log.Printf("Workspace has uncommitted changes, they are exported as of commit %s", commit)
+		}
+	}
+
+	manifest, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot manifest: %w", err)
+	}
+
+	names := make([]string, 0, len(idx.stores))
+	var embeddingKeys []string
+	for _, store := range idx.stores {
+		if quantized, isQuantized := store.(*quantizedStore); isQuantized {
+			store = quantized.VectorStore
+		}
+
+		chromemStore, ok := store.(*chromemStore)
+		if !ok {
+			return nil, fmt.Errorf("snapshots are only supported by the %s storage backend", BackendChromem)
+		}
+		names = append(names, chromemStore.collection.Name)
+
+		// Quantized chunks are rescored with their full-precision embeddings
+		docs, err := chromemStore.List(ctx, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list indexed chunks: %w", err)
+		}
+		for _, doc := range docs {
+			embeddingKeys = append(embeddingKeys, idx.embeddings.documentKey(*doc))
+		}
+	}
+
+	var data bytes.Buffer
+	err = idx.db.ExportToWriter(&data, true, "", names...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export vector db: %w", err)
+	}
+
+	archive := tar.NewWriter(w)
+	err = writeSnapshotEntry(archive, snapshotManifestEntry, manifest, snapshot.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeSnapshotEntry(archive, snapshotIndexEntry, data.Bytes(), snapshot.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chunks share embeddings when their text is the same
+	slices.Sort(embeddingKeys)
+	for _, key := range slices.Compact(embeddingKeys) {
+		embedding, found := idx.embeddings.get(key)
+		if !found {
+			continue
+		}
+
+		err = writeSnapshotEntry(archive, snapshotEmbeddingsDir+key, encodeEmbedding(embedding), snapshot.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write snapshot archive: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+func writeSnapshotEntry(archive *tar.Writer, name string, data []byte, modTime time.Time) error {
+	err := archive.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot archive: %w", err)
+	}
+
+	_, err = archive.Write(data)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot archive: %w", err)
+	}
+
+	return nil
+}
+
+// Import replaces the workspace's index with an exported snapshot. Chunks keep
+// the content hash of their file, so only files whose content differs from the
+// snapshot are re-indexed. The index must not be open while importing.
+func Import(ctx context.Context, workspaceRoot string, cfg Config, r io.Reader) (*Snapshot, error) {
+	if cfg.Storage.Backend != "" && cfg.Storage.Backend != BackendChromem {
+		return nil, fmt.Errorf("snapshots are only supported by the %s storage backend", BackendChromem)
+	}
+
+	snapshot, data, embeddings, err := readSnapshot(r)
+	if err != nil {
+		return nil, err
+	}
+
+	embedder, err := NewEmbedder(cfg.Embedder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedder: %w", err)
+	}
+
+	if snapshot.SchemaVersion != schemaVersion {
+		return nil, fmt.Errorf("snapshot has schema version %d, expected %d", snapshot.SchemaVersion, schemaVersion)
+	}
+
+	if snapshot.Model != embedder.Model() {
+		return nil, fmt.Errorf("snapshot was embedded with %s, but %s is configured", snapshot.Model, embedder.Model())
+	}
+
+	dbPath, err := cfg.Storage.dbPath(workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := chromem.NewPersistentDB(dbPath, cfg.Storage.Compress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vector db: %w", err)
+	}
+
+	// Drop the current chunks, imported collections don't remove their documents
+	for name := range db.ListCollections() {
+		if strings.HasPrefix(name, collectionPrefix) || name == legacyCollection {
+			err = db.DeleteCollection(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to drop vector db collection: %w", err)
+			}
+		}
+	}
+
+	err = db.ImportFromReader(bytes.NewReader(data), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to import vector db: %w", err)
+	}
+
+	cache, err := newEmbeddingCache(ctx, db, dbPath, embedder)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, embedding := range embeddings {
+		err = cache.put(key, embedding)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The manifest is rebuilt from the imported chunks on the next start
+	err = os.Remove(filepath.Join(dbPath, manifestFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove index manifest: %w", err)
+	}
+
+	// HNSW graphs are rebuilt from the imported embeddings
+	graphs, _ := filepath.Glob(filepath.Join(dbPath, graphFilePrefix+"*.gob"))
+	for _, graph := range graphs {
+		err = os.Remove(graph)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove HNSW graph: %w", err)
+		}
+	}
+
+	schemaCollection, err := db.GetOrCreateCollection(schemaCollection, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index schema collection: %w", err)
+	}
+
+	// A different quantization than configured rebuilds the index when it's opened
+	imported := &schema{
+		Version:      snapshot.SchemaVersion,
+		Model:        snapshot.Model,
+		Quantization: cmp.Or(snapshot.Quantization, QuantizationNone),
+		Parsers:      snapshot.Parsers,
+	}
+	err = imported.save(ctx, schemaCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// readSnapshot reads the manifest, exported vector db & cached embeddings from
+// a snapshot archive
+func readSnapshot(r io.Reader) (*Snapshot, []byte, map[string][]float32, error) {
+	var snapshot *Snapshot
+	var data []byte
+	embeddings := map[string][]float32{} // cache key -> embedding
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to read snapshot archive: %w", err)
+		}
+
+		switch header.Name {
+		case snapshotManifestEntry:
+			snapshot = &Snapshot{}
+			err = json.NewDecoder(archive).Decode(snapshot)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to decode snapshot manifest: %w", err)
+			}
+		case snapshotIndexEntry:
+			data, err = io.ReadAll(archive)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to read snapshot archive: %w", err)
+			}
+		default:
+			// Keys are hex hashes, anything else isn't a cache entry
+			key, found := strings.CutPrefix(header.Name, snapshotEmbeddingsDir)
+			if _, err := strconv.ParseUint(key, 16, 64); !found || err != nil {
+				continue
+			}
+
+			entry, err := io.ReadAll(archive)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to read snapshot archive: %w", err)
+			}
+
+			if len(entry) > 0 && len(entry)%4 == 0 {
+				embeddings[key] = decodeEmbedding(entry)
+			}
+		}
+	}
+
+	if snapshot == nil || data == nil {
+		return nil, nil, nil, fmt.Errorf("not an index snapshot")
+	}
+
+	return snapshot, data, embeddings, nil
+}
@@ -0,0 +1,122 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	for _, method := range []string{QuantizationNone, QuantizationInt8} {
+		t.Run(method, func(t *testing.T) {
+			ctx := context.Background()
+			embedder, provider := embeddingServer(t)
+			cfg := Config{Embedder: embedder, Quantization: QuantizationConfig{Method: method, Rescore: defaultRescore}}
+
+			source := t.TempDir()
+			idx := openIndex(t, source, cfg)
+			indexFiles(t, idx,
+				writeFile(t, source, "a.go", "go", funcChunk("openStore", "return openDB(dir)")),
+				writeFile(t, source, "b.go", "go", funcChunk("closeStore", "return db.Close()")),
+			)
+
+			var archive bytes.Buffer
+			snapshot, err := idx.Export(ctx, &archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			closeIndex(t, idx)
+
+			if snapshot.Model != "ollama:"+defaultOllamaModel || snapshot.Quantization != method {
+				t.Errorf("exported snapshot %+v", snapshot)
+			}
+
+			// Another checkout, where one file has changed since the export
+			target := t.TempDir()
+			unchanged := writeFile(t, target, "a.go", "go", funcChunk("openStore", "return openDB(dir)"))
+			changed := writeFile(t, target, "b.go", "go", funcChunk("closeStore", "return nil"))
+
+			imported, err := Import(ctx, target, cfg, &archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !imported.CreatedAt.Equal(snapshot.CreatedAt) {
+				t.Errorf("imported snapshot created at %v, expected %v", imported.CreatedAt, snapshot.CreatedAt)
+			}
+
+			idx = openIndex(t, target, cfg)
+			defer closeIndex(t, idx)
+
+			if idx.IsStale(unchanged.Path) || !idx.IsStale(changed.Path) {
+				t.Errorf("stale unchanged file: %v, changed file: %v", idx.IsStale(unchanged.Path), idx.IsStale(changed.Path))
+			}
+
+			page, err := idx.Search(ctx, "open store", SearchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Results) == 0 || page.Results[0].ID != "a.go::openStore" {
+				t.Errorf("imported index found %v", searchIDs(page))
+			}
+
+			// Imported chunks reuse their exported embeddings
+			provider.embedded.Store(0)
+			indexFiles(t, idx, unchanged)
+			if n := provider.embedded.Load(); n != 0 {
+				t.Errorf("re-indexing an imported file embedded %d texts", n)
+			}
+		})
+	}
+}
+
+func TestSnapshotImportErrors(t *testing.T) {
+	ctx := context.Background()
+	embedder, _ := embeddingServer(t)
+	cfg := Config{Embedder: embedder}
+
+	source := t.TempDir()
+	idx := openIndex(t, source, cfg)
+	indexFiles(t, idx, writeFile(t, source, "a.go", "go", funcChunk("openStore", "return nil")))
+
+	var archive bytes.Buffer
+	_, err := idx.Export(ctx, &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeIndex(t, idx)
+
+	otherModel := cfg
+	otherModel.Embedder.Model = "other-model"
+	sqlite := cfg
+	sqlite.Storage.Backend = BackendSQLite
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		archive []byte
+		err     string
+	}{
+		{"other model", otherModel, archive.Bytes(), "was embedded with"},
+		{"sqlite backend", sqlite, archive.Bytes(), "only supported by the chromem"},
+		{"not a snapshot", cfg, []byte("not a tar archive"), "snapshot"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Import(ctx, t.TempDir(), test.cfg, bytes.NewReader(test.archive))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("import returned %v, expected an error containing %q", err, test.err)
+			}
+		})
+	}
+
+	// Exporting the sqlite backend fails rather than writing an empty snapshot
+	idx = openIndex(t, t.TempDir(), sqlite)
+	defer closeIndex(t, idx)
+
+	_, err = idx.Export(ctx, &bytes.Buffer{})
+	if err == nil {
+		t.Error("exported the sqlite backend")
+	}
+}
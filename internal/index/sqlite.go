@@ -0,0 +1,362 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync/atomic"
+
+	"github.com/philippgille/chromem-go"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteFile holds the chunks of every file type when the SQLite backend is used
+const sqliteFile = "chunks.sqlite"
+
+// sqliteSchema keeps the metadata as JSON, queryable with json_extract, and
+// indexes the columns chunks are deleted by
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS chunks (
+	id        TEXT PRIMARY KEY,
+	type      TEXT NOT NULL,
+	file      TEXT NOT NULL,
+	language  TEXT NOT NULL,
+	content   TEXT NOT NULL,
+	metadata  TEXT NOT NULL,
+	embedding BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS chunks_type_file ON chunks (type, file);
+CREATE INDEX IF NOT EXISTS chunks_type_language ON chunks (type, language);
+`
+
+// openSQLite opens the chunk database, creating its tables on first use
+func openSQLite(path string) (*sql.DB, error) {
+	// WAL lets searches read while files are indexed, the busy timeout makes
+	// writers wait for each other instead of failing
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create chunk tables: %w", err)
+	}
+
+	return db, nil
+}
+
+// sqliteStore keeps the documents of one file type in a SQLite table, so they
+// are written transactionally & searched without loading every chunk in memory
+type sqliteStore struct {
+	db       *sql.DB
+	fileType string
+	count    atomic.Int64 // counted on open, then updated by every write
+}
+
+func newSQLiteStore(db *sql.DB, fileType string) (*sqliteStore, error) {
+	store := &sqliteStore{db: db, fileType: fileType}
+
+	var count int64
+	err := db.QueryRow(`SELECT COUNT(*) FROM chunks WHERE type = ?`, fileType).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count chunks: %w", err)
+	}
+	store.count.Store(count)
+
+	return store, nil
+}
+
+func (s *sqliteStore) Add(ctx context.Context, docs []chromem.Document) error {
+	return s.write(ctx, func(tx *sql.Tx) (int64, error) {
+		return s.insert(ctx, tx, docs)
+	})
+}
+
+func (s *sqliteStore) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.write(ctx, func(tx *sql.Tx) (int64, error) {
+		return s.delete(ctx, tx, ids)
+	})
+}
+
+// Replace deletes & adds the documents in one transaction, so a file is never
+// left without its chunks
+func (s *sqliteStore) Replace(ctx context.Context, ids []string, docs []chromem.Document) error {
+	return s.write(ctx, func(tx *sql.Tx) (int64, error) {
+		removed, err := s.delete(ctx, tx, ids)
+		if err != nil {
+			return 0, err
+		}
+
+		added, err := s.insert(ctx, tx, docs)
+		return removed + added, err
+	})
+}
+
+func (s *sqliteStore) DeleteFile(ctx context.Context, filePath string) error {
+	return s.write(ctx, func(tx *sql.Tx) (int64, error) {
+		return rowsDeleted(tx.ExecContext(ctx, `DELETE FROM chunks WHERE type = ? AND file = ?`, s.fileType, filePath))
+	})
+}
+
+func (s *sqliteStore) DeleteLanguage(ctx context.Context, language string) error {
+	return s.write(ctx, func(tx *sql.Tx) (int64, error) {
+		return rowsDeleted(tx.ExecContext(ctx, `DELETE FROM chunks WHERE type = ? AND language = ?`, s.fileType, language))
+	})
+}
+
+func (s *sqliteStore) Clear(ctx context.Context) error {
+	return s.write(ctx, func(tx *sql.Tx) (int64, error) {
+		return rowsDeleted(tx.ExecContext(ctx, `DELETE FROM chunks WHERE type = ?`, s.fileType))
+	})
+}
+
+// insert adds or replaces documents, returning the change in their number
+func (s *sqliteStore) insert(ctx context.Context, tx *sql.Tx, docs []chromem.Document) (int64, error) {
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	// Replaced documents are deleted first, so they're told apart from new ones
+	change, err := s.delete(ctx, tx, documentIDs(docs))
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR REPLACE INTO chunks (id, type, file, language, content, metadata, embedding)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, doc := range docs {
+		if len(doc.Embedding) == 0 {
+			return 0, fmt.Errorf("document %s has no embedding", doc.ID)
+		}
+
+		metadata, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = stmt.ExecContext(
+			ctx,
+			doc.ID,
+			s.fileType,
+			doc.Metadata["file"],
+			doc.Metadata["language"],
+			doc.Content,
+			string(metadata),
+			encodeEmbedding(normalized(doc.Embedding)),
+		)
+		if err != nil {
+			return 0, err
+		}
+		change++
+	}
+
+	return change, nil
+}
+
+// delete removes documents by ID, returning the change in their number
+func (s *sqliteStore) delete(ctx context.Context, tx *sql.Tx, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `DELETE FROM chunks WHERE type = ? AND id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var change int64
+	for _, id := range ids {
+		n, err := rowsDeleted(stmt.ExecContext(ctx, s.fileType, id))
+		if err != nil {
+			return 0, err
+		}
+		change += n
+	}
+
+	return change, nil
+}
+
+// rowsDeleted returns the change in the number of documents made by a DELETE
+func rowsDeleted(result sql.Result, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return -n, err
+}
+
+func (s *sqliteStore) Get(ctx context.Context, id string) (chromem.Document, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT content, metadata, embedding FROM chunks WHERE type = ? AND id = ?`, s.fileType, id)
+
+	doc := chromem.Document{ID: id}
+	var metadata string
+	var embedding []byte
+	err := row.Scan(&doc.Content, &metadata, &embedding)
+	if errors.Is(err, sql.ErrNoRows) {
+		return chromem.Document{}, fmt.Errorf("document not found: %s", id)
+	}
+	if err != nil {
+		return chromem.Document{}, fmt.Errorf("failed to read document %s: %w", id, err)
+	}
+
+	err = json.Unmarshal([]byte(metadata), &doc.Metadata)
+	if err != nil {
+		return chromem.Document{}, fmt.Errorf("failed to decode metadata of document %s: %w", id, err)
+	}
+
+	doc.Embedding = decodeEmbedding(embedding)
+	return doc, nil
+}
+
+// Query compares the embedding to every stored one while streaming the rows,
+// so embeddings are never all held in memory
+func (s *sqliteStore) Query(ctx context.Context, embedding []float32, n int) ([]chromem.Result, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, embedding FROM chunks WHERE type = ?`, s.fileType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks: %w", err)
+	}
+	defer rows.Close()
+
+	query := normalized(embedding)
+	var results []chromem.Result
+	for rows.Next() {
+		var id string
+		var stored []byte
+		err = rows.Scan(&id, &stored)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query chunks: %w", err)
+		}
+
+		results = append(results, chromem.Result{ID: id, Similarity: dot(query, decodeEmbedding(stored))})
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks: %w", err)
+	}
+
+	slices.SortFunc(results, byResultSimilarity)
+	if len(results) > n {
+		results = results[:n]
+	}
+
+	return results, nil
+}
+
+func (s *sqliteStore) List(ctx context.Context, embeddings bool) ([]*chromem.Document, error) {
+	columns := "id, content, metadata"
+	if embeddings {
+		columns += ", embedding"
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+columns+` FROM chunks WHERE type = ?`, s.fileType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []*chromem.Document
+	for rows.Next() {
+		doc := &chromem.Document{}
+		var metadata string
+		var embedding []byte
+
+		dest := []any{&doc.ID, &doc.Content, &metadata}
+		if embeddings {
+			dest = append(dest, &embedding)
+		}
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list chunks: %w", err)
+		}
+
+		err = json.Unmarshal([]byte(metadata), &doc.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode metadata of document %s: %w", doc.ID, err)
+		}
+
+		if embeddings {
+			doc.Embedding = decodeEmbedding(embedding)
+		}
+		docs = append(docs, doc)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+
+	return docs, nil
+}
+
+func (s *sqliteStore) Count() int {
+	return int(s.count.Load())
+}
+
+// write runs fn in a transaction & applies the change in the number of
+// documents it returns to the count once committed
+func (s *sqliteStore) write(ctx context.Context, fn func(tx *sql.Tx) (int64, error)) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	defer tx.Rollback()
+
+	change, err := fn(tx)
+	if err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+
+	s.count.Add(change)
+	return nil
+}
+
+// encodeEmbedding packs an embedding as little endian float32s
+func encodeEmbedding(embedding []float32) []byte {
+	data := make([]byte, 4*len(embedding))
+	for i, value := range embedding {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+
+	return data
+}
+
+func decodeEmbedding(data []byte) []float32 {
+	embedding := make([]float32, len(data)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+
+	return embedding
+}
@@ -0,0 +1,215 @@
+package index
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+// storeDoc returns a document of a file with a unit embedding along an axis
+func storeDoc(filePath, path, language string, axis int) chromem.Document {
+	embedding := make([]float32, 4)
+	embedding[axis] = 1
+
+	return chromem.Document{
+		ID:        filePath + "::" + path,
+		Metadata:  map[string]string{"file": filePath, "path": path, "language": language},
+		Embedding: embedding,
+		Content:   path,
+	}
+}
+
+func storeIDs(t *testing.T, store VectorStore) []string {
+	t.Helper()
+
+	docs, err := store.List(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+
+	slices.Sort(ids)
+	return ids
+}
+
+func TestVectorStores(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		write func(store VectorStore) error
+		ids   []string // stored after the write
+	}{
+		{
+			name: "add replaces",
+			write: func(store VectorStore) error {
+				return store.Add(ctx, []chromem.Document{storeDoc("a.go", "Open", "go", 3)})
+			},
+			ids: []string{"a.go::Close", "a.go::Open", "b.py::load"},
+		},
+		{
+			name:  "delete ignores unknown IDs",
+			write: func(store VectorStore) error { return store.Delete(ctx, "a.go::Open", "c.go::missing") },
+			ids:   []string{"a.go::Close", "b.py::load"},
+		},
+		{
+			name: "replace",
+			write: func(store VectorStore) error {
+				return store.Replace(ctx, []string{"a.go::Open", "a.go::Close"}, []chromem.Document{
+					storeDoc("a.go", "Open", "go", 0),
+					storeDoc("a.go", "Reopen", "go", 1),
+				})
+			},
+			ids: []string{"a.go::Open", "a.go::Reopen", "b.py::load"},
+		},
+		{
+			name:  "delete file",
+			write: func(store VectorStore) error { return store.DeleteFile(ctx, "a.go") },
+			ids:   []string{"b.py::load"},
+		},
+		{
+			name:  "delete language",
+			write: func(store VectorStore) error { return store.DeleteLanguage(ctx, "python") },
+			ids:   []string{"a.go::Close", "a.go::Open"},
+		},
+		{
+			name:  "clear",
+			write: func(store VectorStore) error { return store.Clear(ctx) },
+			ids:   []string{},
+		},
+	}
+
+	for _, backend := range []string{BackendChromem, BackendSQLite} {
+		for _, test := range tests {
+			t.Run(backend+"/"+test.name, func(t *testing.T) {
+				dbPath := t.TempDir()
+				db, err := chromem.NewPersistentDB(dbPath, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				stores, closeStores, err := openStores(db, dbPath, StorageConfig{Backend: backend})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer closeStores()
+
+				store := stores[string(parser.FileTypeSrc)]
+				err = store.Add(ctx, []chromem.Document{
+					storeDoc("a.go", "Open", "go", 0),
+					storeDoc("a.go", "Close", "go", 1),
+					storeDoc("b.py", "load", "python", 2),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = test.write(store)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ids := storeIDs(t, store)
+				if !slices.Equal(ids, test.ids) {
+					t.Errorf("stored %v, expected %v", ids, test.ids)
+				}
+				if store.Count() != len(test.ids) {
+					t.Errorf("count %d, expected %d", store.Count(), len(test.ids))
+				}
+
+				// Other file types are stored apart
+				if n := stores[string(parser.FileTypeDocs)].Count(); n != 0 {
+					t.Errorf("docs store holds %d documents, expected none", n)
+				}
+
+				if len(test.ids) == 0 {
+					return
+				}
+
+				results, err := store.Query(ctx, []float32{0, 0, 0, 1}, len(test.ids))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(results) != len(test.ids) {
+					t.Fatalf("query returned %d results, expected %d", len(results), len(test.ids))
+				}
+
+				doc, err := store.Get(ctx, results[0].ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if doc.Content != strings.SplitN(doc.ID, "::", 2)[1] || len(doc.Embedding) != 4 {
+					t.Errorf("got document %+v", doc)
+				}
+			})
+		}
+	}
+}
+
+func TestSQLiteStoreCountSurvivesReopening(t *testing.T) {
+	ctx := context.Background()
+	dbPath := t.TempDir()
+
+	// Adding the same documents after reopening replaces them
+	for range 2 {
+		db, err := chromem.NewPersistentDB(dbPath, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stores, closeStores, err := openStores(db, dbPath, StorageConfig{Backend: BackendSQLite})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := stores[string(parser.FileTypeSrc)]
+		err = store.Add(ctx, []chromem.Document{storeDoc("a.go", "Open", "go", 0), storeDoc("a.go", "Close", "go", 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if store.Count() != 2 {
+			t.Errorf("count %d, expected 2", store.Count())
+		}
+		closeStores()
+	}
+}
+
+func TestIndexKeepsChunksWhenEmbeddingFails(t *testing.T) {
+	for _, backend := range []string{BackendChromem, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			root := t.TempDir()
+			embedder, provider := embeddingServer(t)
+			cfg := Config{Embedder: embedder, Storage: StorageConfig{Backend: backend}}
+
+			idx := openIndex(t, root, cfg)
+			defer closeIndex(t, idx)
+
+			indexFiles(t, idx, writeFile(t, root, "a.go", "go", funcChunk("Open", "return nil")))
+
+			provider.failing.Store(true)
+			changed := writeFile(t, root, "a.go", "go", funcChunk("Reopen", "return nil"))
+			err := idx.Index(context.Background(), changed)
+			if err == nil {
+				t.Fatal("indexing succeeded without an embedding provider")
+			}
+
+			_, err = idx.GetChunk(context.Background(), "a.go::Open")
+			if err != nil {
+				t.Errorf("old chunk is gone after a failed re-index: %v", err)
+			}
+
+			if n := idx.stores[string(parser.FileTypeSrc)].Count(); n != 1 {
+				t.Errorf("%d stored chunks, expected 1", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cespare/xxhash"
+)
+
+const (
+	// DBLocationWorkspace keeps the index in the workspace, under .codesearch/db
+	DBLocationWorkspace = "workspace"
+	// DBLocationCache keeps the index in the user cache dir ($XDG_CACHE_HOME on
+	// Linux), keyed by the absolute workspace path
+	DBLocationCache = "cache"
+
+	// BackendChromem keeps chunks in memory, persisted as a file per chunk
+	BackendChromem = "chromem"
+	// BackendSQLite keeps chunks in an embedded SQLite database
+	BackendSQLite = "sqlite"
+
+	workspaceDBDir = ".codesearch/db"
+	cacheDBDir     = "code-search-mcp"
+)
+
+// StorageConfig selects where & how the vector db is persisted
+type StorageConfig struct {
+	Location string // workspace or cache, ignored when Path is set
+	Path     string // explicit db directory, relative paths are resolved against the workspace
+	Compress bool   // gzip the persisted documents
+	Backend  string // chromem or sqlite, where chunks are stored
+}
+
+// StorageConfigFromEnv reads the vector db settings from the environment
+func StorageConfigFromEnv() StorageConfig {
+	compress, _ := strconv.ParseBool(os.Getenv("CODE_SEARCH_DB_COMPRESS"))
+
+	return StorageConfig{
+		Location: os.Getenv("CODE_SEARCH_DB_LOCATION"),
+		Path:     os.Getenv("CODE_SEARCH_DB_PATH"),
+		Compress: compress,
+		Backend:  os.Getenv("CODE_SEARCH_DB_BACKEND"),
+	}
+}
+
+// dbPath resolves the directory of the vector db for a workspace, independent
+// of the process working directory
+func (c StorageConfig) dbPath(workspaceRoot string) (string, error) {
+	root, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace root: %w", err)
+	}
+
+	if c.Path != "" {
+		if filepath.IsAbs(c.Path) {
+			return c.Path, nil
+		}
+		return filepath.Join(root, c.Path), nil
+	}
+
+	switch c.Location {
+	case "", DBLocationWorkspace:
+		return filepath.Join(root, workspaceDBDir), nil
+	case DBLocationCache:
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find user cache dir: %w", err)
+		}
+
+		// The base name keeps the dir recognizable, the hash keeps repos apart
+		key := fmt.Sprintf("%s-%x", filepath.Base(root), xxhash.Sum64String(root))
+		return filepath.Join(cacheDir, cacheDBDir, key), nil
+	default:
+		return "", fmt.Errorf("unknown db location: %s", c.Location)
+	}
+}
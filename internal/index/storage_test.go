@@ -0,0 +1,104 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStorageDBPath(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	root := filepath.Join(t.TempDir(), "repo")
+	tests := []struct {
+		name   string
+		cfg    StorageConfig
+		path   string // expected db path
+		hashed bool   // whether a hash of the workspace path follows
+		failed bool
+	}{
+		{
+			name: "workspace by default",
+			cfg:  StorageConfig{},
+			path: filepath.Join(root, ".codesearch", "db"),
+		},
+		{
+			name: "workspace",
+			cfg:  StorageConfig{Location: DBLocationWorkspace},
+			path: filepath.Join(root, ".codesearch", "db"),
+		},
+		{
+			name:   "cache, keyed by the workspace",
+			cfg:    StorageConfig{Location: DBLocationCache},
+			path:   filepath.Join(cacheDir, "code-search-mcp", "repo-"),
+			hashed: true,
+		},
+		{
+			name: "relative path anchored to the workspace",
+			cfg:  StorageConfig{Location: DBLocationCache, Path: "index/db"},
+			path: filepath.Join(root, "index", "db"),
+		},
+		{
+			name: "absolute path",
+			cfg:  StorageConfig{Path: "/var/lib/code-search"},
+			path: "/var/lib/code-search",
+		},
+		{
+			name:   "unknown location",
+			cfg:    StorageConfig{Location: "memory"},
+			failed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, err := test.cfg.dbPath(root)
+			if (err != nil) != test.failed {
+				t.Fatalf("resolving the db path returned %v", err)
+			}
+
+			if test.hashed {
+				if !strings.HasPrefix(path, test.path) || len(path) == len(test.path) {
+					t.Errorf("db path %s, expected %s<hash>", path, test.path)
+				}
+			} else if path != test.path {
+				t.Errorf("db path %s, expected %s", path, test.path)
+			}
+		})
+	}
+}
+
+func TestStorageDBPathIgnoresWorkingDir(t *testing.T) {
+	root := t.TempDir()
+	cfg := StorageConfig{Location: DBLocationCache}
+
+	expected, err := cfg.dbPath(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A relative workspace root resolves to the same repo, and the same db
+	t.Chdir(filepath.Dir(root))
+	path, err := cfg.dbPath(filepath.Base(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != expected {
+		t.Errorf("db path %s from the parent dir, expected %s", path, expected)
+	}
+
+	// Another repo gets another db
+	other, err := cfg.dbPath(filepath.Join(root, "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == expected {
+		t.Errorf("two repos share the db %s", other)
+	}
+}
@@ -0,0 +1,148 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// VectorStore holds the chunk documents of one file type & their embeddings
+type VectorStore interface {
+	// Add inserts or replaces documents, they must carry their embedding
+	Add(ctx context.Context, docs []chromem.Document) error
+	// Delete removes documents by ID, unknown IDs are ignored
+	Delete(ctx context.Context, ids ...string) error
+	// Replace removes documents by ID & adds others in one step, e.g. the old &
+	// new chunks of a re-indexed file
+	Replace(ctx context.Context, ids []string, docs []chromem.Document) error
+	// DeleteFile removes the documents of a file
+	DeleteFile(ctx context.Context, filePath string) error
+	// DeleteLanguage removes the documents of every file in a language
+	DeleteLanguage(ctx context.Context, language string) error
+	// Clear removes every document
+	Clear(ctx context.Context) error
+	// Get returns a document by ID
+	Get(ctx context.Context, id string) (chromem.Document, error)
+	// Query returns the IDs & similarities of the n documents most similar to the embedding
+	Query(ctx context.Context, embedding []float32, n int) ([]chromem.Result, error)
+	// List returns every document, embeddings are left out unless requested
+	List(ctx context.Context, embeddings bool) ([]*chromem.Document, error)
+	// Count returns the number of documents
+	Count() int
+}
+
+// openStores opens a chunk store per file type on the configured backend. The
+// returned func closes them.
+func openStores(db *chromem.DB, dbPath string, cfg StorageConfig) (map[string]VectorStore, func() error, error) {
+	stores := map[string]VectorStore{}
+
+	switch cfg.Backend {
+	case "", BackendChromem:
+		for _, fileType := range partitions {
+			// Documents are embedded by the index before they're added
+			collection, err := db.GetOrCreateCollection(collectionPrefix+string(fileType), nil, nil)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create vector db collection: %w", err)
+			}
+
+			stores[string(fileType)] = &chromemStore{collection: collection}
+		}
+
+		return stores, func() error { return nil }, nil
+	case BackendSQLite:
+		sqlDB, err := openSQLite(filepath.Join(dbPath, sqliteFile))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, fileType := range partitions {
+			store, err := newSQLiteStore(sqlDB, string(fileType))
+			if err != nil {
+				sqlDB.Close()
+				return nil, nil, err
+			}
+
+			stores[string(fileType)] = store
+		}
+
+		return stores, sqlDB.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
+
+// chromemStore keeps documents in memory in a chromem-go collection, persisted
+// as a file per document
+type chromemStore struct {
+	collection *chromem.Collection
+}
+
+func (s *chromemStore) Add(ctx context.Context, docs []chromem.Document) error {
+	return s.collection.AddDocuments(ctx, docs, runtime.NumCPU())
+}
+
+func (s *chromemStore) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.collection.Delete(ctx, nil, nil, ids...)
+}
+
+// Replace deletes before adding, chromem-go has no transactions
+func (s *chromemStore) Replace(ctx context.Context, ids []string, docs []chromem.Document) error {
+	err := s.Delete(ctx, ids...)
+	if err != nil || len(docs) == 0 {
+		return err
+	}
+
+	return s.Add(ctx, docs)
+}
+
+func (s *chromemStore) DeleteFile(ctx context.Context, filePath string) error {
+	return s.collection.Delete(ctx, map[string]string{"file": filePath}, nil)
+}
+
+func (s *chromemStore) DeleteLanguage(ctx context.Context, language string) error {
+	return s.collection.Delete(ctx, map[string]string{"language": language}, nil)
+}
+
+func (s *chromemStore) Clear(ctx context.Context) error {
+	return s.Delete(ctx, s.collection.ListIDs(ctx)...)
+}
+
+func (s *chromemStore) Get(ctx context.Context, id string) (chromem.Document, error) {
+	return s.collection.GetByID(ctx, id)
+}
+
+// Query caps n at the collection size, since chromem-go rejects larger requests
+func (s *chromemStore) Query(ctx context.Context, embedding []float32, n int) ([]chromem.Result, error) {
+	n = min(n, s.collection.Count())
+	if n == 0 {
+		return nil, nil
+	}
+
+	return s.collection.QueryEmbedding(ctx, embedding, n, nil, nil)
+}
+
+// List is shallow, the embeddings are in memory anyway & never modified
+func (s *chromemStore) List(ctx context.Context, _ bool) ([]*chromem.Document, error) {
+	return s.collection.ListDocumentsShallow(ctx)
+}
+
+func (s *chromemStore) Count() int {
+	return s.collection.Count()
+}
+
+// documentIDs returns the IDs of documents
+func documentIDs(docs []chromem.Document) []string {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+
+	return ids
+}
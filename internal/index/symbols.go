@@ -0,0 +1,200 @@
+package index
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/suvaidkhan/code-explore-mcp/internal/parser"
+)
+
+// SymbolMatch selects how FindSymbol compares names
+type SymbolMatch string
+
+const (
+	SymbolMatchExact  SymbolMatch = "exact"
+	SymbolMatchPrefix SymbolMatch = "prefix"
+	SymbolMatchFuzzy  SymbolMatch = "fuzzy"
+)
+
+// duplicatePathSuffix matches the counter appended to conflicting chunk paths
+var duplicatePathSuffix = regexp.MustCompile(`-\d+$`)
+
+// symbolTable maps symbol names, taken from chunk paths, to the chunks defining
+// them. A method chunk "Type::method" is found both as "method" & "Type::method".
+type symbolTable struct {
+	names map[string]map[string]string // lowercase name -> chunk ID -> name
+	ids   map[string][]string          // chunk ID -> lowercase names
+	mu    sync.RWMutex
+}
+
+// SymbolResult is a chunk found by FindSymbol
+type SymbolResult struct {
+	*SearchResult
+	Distance int  // edit distance between the query and the symbol name
+	Exact    bool // name matches the query including case
+}
+
+// CompareSymbolResults orders symbol results closest match first, for merging
+// the results of several lookups
+func CompareSymbolResults(a, b *SymbolResult) int {
+	switch {
+	case a.Distance != b.Distance:
+		return a.Distance - b.Distance
+	case a.Exact && !b.Exact:
+		return -1
+	case !a.Exact && b.Exact:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// symbolHit is a chunk whose symbol name matched a lookup
+type symbolHit struct {
+	id       string
+	distance int  // edit distance between the query and the name
+	exact    bool // name matches the query including case
+}
+
+func newSymbolTable() *symbolTable {
+	return &symbolTable{
+		names: map[string]map[string]string{},
+		ids:   map[string][]string{},
+	}
+}
+
+// add registers the symbol names of a chunk
+func (t *symbolTable) add(id, path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.removeLocked(id)
+
+	segments := strings.Split(path, "::")
+	segments[len(segments)-1] = duplicatePathSuffix.ReplaceAllString(segments[len(segments)-1], "")
+
+	names := []string{segments[len(segments)-1]}
+	if len(segments) > 1 {
+		names = append(names, strings.Join(segments, "::"))
+	}
+
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if t.names[key] == nil {
+			t.names[key] = map[string]string{}
+		}
+
+		t.names[key][id] = name
+		t.ids[id] = append(t.ids[id], key)
+	}
+}
+
+// remove drops chunks from the table
+func (t *symbolTable) remove(ids ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, id := range ids {
+		t.removeLocked(id)
+	}
+}
+
+func (t *symbolTable) removeLocked(id string) {
+	for _, key := range t.ids[id] {
+		delete(t.names[key], id)
+		if len(t.names[key]) == 0 {
+			delete(t.names, key)
+		}
+	}
+
+	delete(t.ids, id)
+}
+
+// lookup finds chunks whose symbol names match the query, closest matches first.
+// Names are compared case-insensitively, exact-case matches rank higher on ties.
+func (t *symbolTable) lookup(query string, match SymbolMatch) []symbolHit {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	key := strings.ToLower(query)
+	maxDistance := max(1, len(key)/4)
+
+	best := map[string]symbolHit{}
+	for name, chunks := range t.names {
+		var distance int
+		switch match {
+		case SymbolMatchExact:
+			if name != key {
+				continue
+			}
+		case SymbolMatchPrefix:
+			if !strings.HasPrefix(name, key) {
+				continue
+			}
+			distance = len(name) - len(key)
+		default:
+			distance = levenshtein(key, name)
+			if distance > maxDistance {
+				continue
+			}
+		}
+
+		for id, original := range chunks {
+			hit := symbolHit{id: id, distance: distance, exact: original == query}
+			prev, seen := best[id]
+			if !seen || hit.distance < prev.distance || hit.distance == prev.distance && hit.exact {
+				best[id] = hit
+			}
+		}
+	}
+
+	hits := make([]symbolHit, 0, len(best))
+	for _, hit := range best {
+		hits = append(hits, hit)
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].distance != hits[j].distance {
+			return hits[i].distance < hits[j].distance
+		}
+		if hits[i].exact != hits[j].exact {
+			return hits[i].exact
+		}
+		return hits[i].id < hits[j].id
+	})
+
+	return hits
+}
+
+// isSymbol reports whether chunks of a kind define a named symbol, rather than
+// being identified by a content hash
+func isSymbol(kind parser.ChunkKind) bool {
+	return kind != parser.KindComment && kind != parser.KindOther
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
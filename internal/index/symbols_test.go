@@ -0,0 +1,105 @@
+package index
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"processFiles", "processFiles", 0},
+		{"processFile", "processFiles", 1},
+		{"procesFiles", "processFiles", 1},
+		{"prcoessFiles", "processFiles", 2},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+
+	for _, test := range tests {
+		if distance := levenshtein(test.a, test.b); distance != test.distance {
+			t.Errorf("levenshtein(%q, %q) = %d, expected %d", test.a, test.b, distance, test.distance)
+		}
+		if distance := levenshtein(test.b, test.a); distance != test.distance {
+			t.Errorf("levenshtein(%q, %q) = %d, expected %d", test.b, test.a, distance, test.distance)
+		}
+	}
+}
+
+func TestSymbolLookup(t *testing.T) {
+	table := newSymbolTable()
+	table.add("analyzer.go::Analyzer::processFiles", "Analyzer::processFiles")
+	table.add("analyzer.go::processFile", "processFile")
+	table.add("watcher.go::Watcher::processPendingFiles", "Watcher::processPendingFiles")
+	table.add("config.go::ProcessFiles-2", "ProcessFiles-2")
+	table.add("config.go::parseConfig", "parseConfig")
+
+	tests := []struct {
+		name  string
+		query string
+		match SymbolMatch
+		ids   []string // expected hits, closest first
+	}{
+		{
+			name:  "exact ignores case, exact case first",
+			query: "processFiles",
+			match: SymbolMatchExact,
+			ids:   []string{"analyzer.go::Analyzer::processFiles", "config.go::ProcessFiles-2"},
+		},
+		{
+			name:  "exact qualified name",
+			query: "watcher::processpendingfiles",
+			match: SymbolMatchExact,
+			ids:   []string{"watcher.go::Watcher::processPendingFiles"},
+		},
+		{
+			name:  "prefix, shortest first",
+			query: "process",
+			match: SymbolMatchPrefix,
+			ids: []string{
+				"analyzer.go::processFile",
+				"analyzer.go::Analyzer::processFiles",
+				"config.go::ProcessFiles-2",
+				"watcher.go::Watcher::processPendingFiles",
+			},
+		},
+		{
+			name:  "fuzzy typo",
+			query: "procesFiles",
+			match: SymbolMatchFuzzy,
+			ids: []string{
+				"analyzer.go::Analyzer::processFiles",
+				"config.go::ProcessFiles-2",
+				"analyzer.go::processFile",
+			},
+		},
+		{
+			name:  "fuzzy too far",
+			query: "parseFlags",
+			match: SymbolMatchFuzzy,
+			ids:   []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ids := []string{}
+			for _, hit := range table.lookup(test.query, test.match) {
+				ids = append(ids, hit.id)
+			}
+
+			if !slices.Equal(ids, test.ids) {
+				t.Errorf("found %v, expected %v", ids, test.ids)
+			}
+		})
+	}
+
+	table.remove("analyzer.go::Analyzer::processFiles")
+	if hits := table.lookup("Analyzer::processFiles", SymbolMatchExact); len(hits) != 0 {
+		t.Errorf("found %v after removing the chunk", hits)
+	}
+}
@@ -0,0 +1,62 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tokenize splits text into lowercase terms. Identifiers are kept whole and
+// also split into their camelCase, PascalCase and snake_case parts, so that
+// "parseHTTPRequest" yields "parsehttprequest", "parse", "http" & "request".
+func tokenize(text string) []string {
+	var terms []string
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			terms = append(terms, strings.ToLower(strings.ReplaceAll(word, "_", "")))
+		}
+
+		for _, part := range parts {
+			if len(part) > 1 {
+				terms = append(terms, strings.ToLower(part))
+			}
+		}
+	}
+
+	return terms
+}
+
+// splitIdentifier splits an identifier at underscores, case changes and
+// letter/digit boundaries, keeping acronyms together ("HTTPServer" -> "HTTP", "Server")
+func splitIdentifier(word string) []string {
+	var parts []string
+
+	for _, segment := range strings.Split(word, "_") {
+		runes := []rune(segment)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+
+			boundary := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+				unicode.IsDigit(prev) != unicode.IsDigit(cur) ||
+				unicode.IsUpper(prev) && unicode.IsUpper(cur) &&
+					i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			if boundary {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+
+	return parts
+}
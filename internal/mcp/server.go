@@ -2,8 +2,12 @@ package mcp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/suvaidkhan/code-explore-mcp/internal/analyzer"
+	"github.com/suvaidkhan/code-explore-mcp/internal/index"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/dustin/go-humanize"
@@ -11,21 +15,29 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
 type Server struct {
-	workspaceRoot string
-	mcp           *server.MCPServer
-	analyzer      *analyzer.Analyzer
+	mcp       *server.MCPServer
+	workspace *analyzer.Workspace
 }
 
-func NewServer(workspaceRoot, version string) (*Server, error) {
-	a, err := analyzer.New(context.Background(), workspaceRoot)
+func NewServer(repos []analyzer.Repo, version string, cfg index.Config) (*Server, error) {
+	w, err := analyzer.NewWorkspace(context.Background(), repos, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	s := &Server{
-		workspaceRoot: workspaceRoot,
-		analyzer:      a,
+		workspace: w,
+	}
+
+	reposDescription := "Only search these repositories (defaults to all)"
+	if aliases := w.Repos(); len(aliases) > 0 {
+		reposDescription += ": " + strings.Join(aliases, ", ")
 	}
 
 	s.mcp = server.NewMCPServer(
@@ -53,12 +65,35 @@ Use the file_types param to filter search results (defaults to ['src', 'docs']):
 - docs: Documentation
 - tests: Tests code
 
-AVOID SEMANTIC SEARCH FOR EXACT MATCHES:
-If you need to find specific names or exact text, use pattern-based tools
-like grep & glob instead:
+Use the languages param to restrict results to some languages, e.g.
+["python"] when the same concept exists in a TypeScript frontend.
+
+Use the kinds param to restrict results to some kinds of code entities, e.g.
+["type", "interface"] for data structures or ["method"] for behavior.
+
+Use the include_paths & exclude_paths params to restrict results to parts of
+the codebase with glob patterns, e.g. ["services/billing/**"] or
+["**/migrations/**"].
+
+When several repositories are indexed, chunk IDs start with the repository
+alias, e.g. api:handlers/user.go::CreateUser, and the repos param restricts
+semantic search to some of them.
+
+Use the limit param to get just the top few results for narrow questions.
+When a response ends with a next offset, pass it as the offset param to get
+the next page of results.
+
+EXACT NAMES:
+Semantic search also ranks chunks by keyword relevance, with identifiers
+split on camelCase & snake_case, so you can mix symbol names and concepts:
 
 Good: "authentication logic and session management"
-Avoid: "AuthService class definition" (use grep instead)
+Good: "AuthService session refresh"
+
+To find every usage of a name or exact text across the codebase, use
+grep_chunks. It matches regular expressions or literal text against indexed
+code and returns chunk IDs with the matching line numbers, so you can keep
+retrieving just the chunks you need.
 
 CHUNK IDs
 Use chunk IDs to retrieve source code:
@@ -75,6 +110,9 @@ If you already know the specific function/class/method/struct/etc and file
 location from previous context, construct the chunk ID yourself and use
 get_chunk_code directly rather than semantic searching again.
 
+If you know a symbol's name but not its file, use find_symbol to get its
+chunk ID. It supports exact, prefix and fuzzy (typo tolerant) matching.
+
 BATCHING:
 Batch operations instead of making separate requests which waste tokens and
 time (round-trips).
@@ -96,6 +134,49 @@ just read it directly with your standard tools.
 				mcp.WithStringItems(),
 				mcp.Description("Filter by file type(s)"),
 			),
+			mcp.WithArray("repos",
+				mcp.WithStringItems(),
+				mcp.Description(reposDescription),
+			),
+			mcp.WithNumber("limit",
+				mcp.Description(fmt.Sprintf("Max number of results (defaults to %d)", index.DefaultLimit)),
+			),
+			mcp.WithNumber("min_score",
+				mcp.Description(fmt.Sprintf("Min similarity score between -1 and 1 (defaults to %.1f)", index.DefaultMinScore)),
+			),
+			mcp.WithNumber("offset",
+				mcp.Description("Number of results to skip, use the next offset from a previous response to page"),
+			),
+			mcp.WithArray("languages",
+				mcp.WithStringItems(),
+				mcp.Description("Filter by language(s): go, javascript, python, typescript"),
+			),
+			mcp.WithArray("kinds",
+				mcp.WithStringItems(),
+				mcp.Description("Filter by chunk kind(s): function, method, class, interface, type, enum, var, const, module, comment, other"),
+			),
+			mcp.WithArray("include_paths",
+				mcp.WithStringItems(),
+				mcp.Description("Only include files matching one of these glob patterns, e.g. services/billing/**"),
+			),
+			mcp.WithArray("exclude_paths",
+				mcp.WithStringItems(),
+				mcp.Description("Exclude files matching one of these glob patterns, e.g. **/migrations/**"),
+			),
+			mcp.WithNumber("diversity",
+				mcp.Description("Between 0 and 1, higher values favour results unlike those ranked above them over closer matches (defaults to 0)"),
+			),
+			mcp.WithNumber("max_per_file",
+				mcp.Description("Max number of results from one file (defaults to no cap)"),
+			),
+			mcp.WithString("group_by",
+				mcp.Enum(index.GroupByFile),
+				mcp.Description("Group results by file, listing the files that matter most with their top chunks, limit & offset then count files"),
+			),
+			mcp.WithString("format",
+				mcp.Enum(formatText, formatJSON),
+				mcp.Description("Result format, json returns structured results with scores (defaults to text)"),
+			),
 		),
 		s.semanticSearch,
 	)
@@ -107,10 +188,75 @@ just read it directly with your standard tools.
 				mcp.Required(),
 				mcp.Description("The chunk ID to find similar code for"),
 			),
+			mcp.WithNumber("limit",
+				mcp.Description(fmt.Sprintf("Max number of results (defaults to %d)", index.DefaultSimilarLimit)),
+			),
+			mcp.WithNumber("min_score",
+				mcp.Description(fmt.Sprintf("Min similarity score between -1 and 1 (defaults to %.1f)", index.DefaultSimilarMinScore)),
+			),
+			mcp.WithNumber("offset",
+				mcp.Description("Number of results to skip, use the next offset from a previous response to page"),
+			),
+			mcp.WithArray("languages",
+				mcp.WithStringItems(),
+				mcp.Description("Filter by language(s): go, javascript, python, typescript"),
+			),
+			mcp.WithArray("kinds",
+				mcp.WithStringItems(),
+				mcp.Description("Filter by chunk kind(s): function, method, class, interface, type, enum, var, const, module, comment, other"),
+			),
+			mcp.WithArray("include_paths",
+				mcp.WithStringItems(),
+				mcp.Description("Only include files matching one of these glob patterns, e.g. services/billing/**"),
+			),
+			mcp.WithArray("exclude_paths",
+				mcp.WithStringItems(),
+				mcp.Description("Exclude files matching one of these glob patterns, e.g. **/migrations/**"),
+			),
+			mcp.WithString("format",
+				mcp.Enum(formatText, formatJSON),
+				mcp.Description("Result format, json returns structured results with scores (defaults to text)"),
+			),
 		),
 		s.findSimilarChunks,
 	)
 
+	s.mcp.AddTool(
+		mcp.NewTool("find_symbol",
+			mcp.WithDescription("Find where a function, type, method or variable is defined by name"),
+			mcp.WithString("name",
+				mcp.Required(),
+				mcp.Description("Symbol name, e.g. processFiles or Analyzer::processFiles"),
+			),
+			mcp.WithString("match",
+				mcp.Enum(string(index.SymbolMatchExact), string(index.SymbolMatchPrefix), string(index.SymbolMatchFuzzy)),
+				mcp.Description("How to match the name (defaults to fuzzy, which includes exact matches)"),
+			),
+		),
+		s.findSymbol,
+	)
+
+	s.mcp.AddTool(
+		mcp.NewTool("grep_chunks",
+			mcp.WithDescription("Find chunks whose code matches a regular expression or literal text, up to 100 chunks"),
+			mcp.WithString("pattern",
+				mcp.Required(),
+				mcp.Description("Regular expression (RE2 syntax) matched against each line"),
+			),
+			mcp.WithBoolean("literal",
+				mcp.Description("Match the pattern as literal text instead of a regular expression"),
+			),
+			mcp.WithBoolean("ignore_case",
+				mcp.Description("Match case-insensitively"),
+			),
+			mcp.WithArray("file_types",
+				mcp.WithStringItems(),
+				mcp.Description("Filter by file type(s)"),
+			),
+		),
+		s.grepChunks,
+	)
+
 	s.mcp.AddTool(
 		mcp.NewTool("get_chunk_code",
 			mcp.WithDescription("Get the actual code you need to examine"),
@@ -147,31 +293,145 @@ func (s *Server) Serve() error {
 
 func (s *Server) semanticSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	query := request.GetString("query", "")
-	fileTypes := request.GetStringSlice("file_types", []string{"src", "docs"})
 
-	results, err := s.analyzer.SemanticSearch(ctx, query, fileTypes)
+	opts := searchOptions(request, index.DefaultSearchOptions())
+	opts.FileTypes = request.GetStringSlice("file_types", opts.FileTypes)
+	opts.Diversity = float32(request.GetFloat("diversity", 0))
+	opts.MaxPerFile = request.GetInt("max_per_file", 0)
+
+	repos := request.GetStringSlice("repos", nil)
+
+	if request.GetString("group_by", "") == index.GroupByFile {
+		page, err := s.workspace.SearchFiles(ctx, query, repos, opts)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+		}
+
+		return filePageResult(page, request.GetString("format", formatText)), nil
+	}
+
+	page, err := s.workspace.SemanticSearch(ctx, query, repos, opts)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+
+	return pageResult(page, request.GetString("format", formatText), "No matching chunks found."), nil
+}
+
+func (s *Server) findSimilarChunks(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chunkID := request.GetString("id", "")
+	opts := searchOptions(request, index.DefaultSimilarOptions())
+
+	page, err := s.workspace.FindSimilarChunks(ctx, chunkID, opts)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
 	}
 
+	return pageResult(page, request.GetString("format", formatText), "No similar chunks found."), nil
+}
+
+// searchOptions reads the paging & filter arguments shared by the search tools
+func searchOptions(request mcp.CallToolRequest, defaults index.SearchOptions) index.SearchOptions {
+	opts := defaults
+	opts.Limit = request.GetInt("limit", defaults.Limit)
+	opts.MinScore = float32(request.GetFloat("min_score", float64(defaults.MinScore)))
+	opts.Offset = request.GetInt("offset", defaults.Offset)
+	opts.Languages = request.GetStringSlice("languages", nil)
+	opts.Kinds = request.GetStringSlice("kinds", nil)
+	opts.IncludePaths = request.GetStringSlice("include_paths", nil)
+	opts.ExcludePaths = request.GetStringSlice("exclude_paths", nil)
+
+	return opts
+}
+
+// pageResult renders a page of search results, pointing to the next page if any.
+// The json format returns the page as structured content, with a JSON text
+// fallback for clients that don't support it.
+func pageResult(page *index.SearchPage, format, emptyMessage string) *mcp.CallToolResult {
+	if format == formatJSON {
+		data, err := json.Marshal(page)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to encode results: %v", err))
+		}
+
+		return mcp.NewToolResultStructured(page, string(data))
+	}
+
+	if len(page.Results) == 0 {
+		return mcp.NewToolResultText(emptyMessage)
+	}
+
+	lines := make([]string, 0, len(page.Results))
+	for _, result := range page.Results {
+		lines = append(lines, result.String())
+	}
+
+	content := strings.Join(lines, "\n")
+	if page.NextOffset > 0 {
+		content += fmt.Sprintf("\n\nMore results available, next offset: %d", page.NextOffset)
+	}
+
+	return mcp.NewToolResultText(content)
+}
+
+// filePageResult renders a page of search results grouped by file, like pageResult
+func filePageResult(page *index.FilePage, format string) *mcp.CallToolResult {
+	if format == formatJSON {
+		data, err := json.Marshal(page)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to encode results: %v", err))
+		}
+
+		return mcp.NewToolResultStructured(page, string(data))
+	}
+
+	if len(page.Files) == 0 {
+		return mcp.NewToolResultText("No matching files found.")
+	}
+
+	files := make([]string, 0, len(page.Files))
+	for _, file := range page.Files {
+		files = append(files, file.String())
+	}
+
+	content := strings.Join(files, "\n\n")
+	if page.NextOffset > 0 {
+		content += fmt.Sprintf("\n\nMore files available, next offset: %d", page.NextOffset)
+	}
+
+	return mcp.NewToolResultText(content)
+}
+
+func (s *Server) findSymbol(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name := request.GetString("name", "")
+	match := request.GetString("match", string(index.SymbolMatchFuzzy))
+
+	results, err := s.workspace.FindSymbol(ctx, name, index.SymbolMatch(match))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Lookup failed: %v", err)), nil
+	}
+
 	if len(results) == 0 {
-		return mcp.NewToolResultText("No matching chunks found."), nil
+		return mcp.NewToolResultText("No matching symbols found."), nil
 	}
 
 	content := strings.Join(results, "\n")
 	return mcp.NewToolResultText(content), nil
 }
 
-func (s *Server) findSimilarChunks(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	chunkID := request.GetString("id", "")
+func (s *Server) grepChunks(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pattern := request.GetString("pattern", "")
+	literal := request.GetBool("literal", false)
+	ignoreCase := request.GetBool("ignore_case", false)
+	fileTypes := request.GetStringSlice("file_types", []string{"src", "docs"})
 
-	results, err := s.analyzer.FindSimilarChunks(ctx, chunkID)
+	results, err := s.workspace.GrepChunks(ctx, pattern, literal, ignoreCase, fileTypes)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
 	}
 
 	if len(results) == 0 {
-		return mcp.NewToolResultText("No similar chunks found."), nil
+		return mcp.NewToolResultText("No matching chunks found."), nil
 	}
 
 	content := strings.Join(results, "\n")
@@ -181,19 +441,19 @@ func (s *Server) findSimilarChunks(ctx context.Context, request mcp.CallToolRequ
 func (s *Server) getChunkCode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	ids := request.GetStringSlice("ids", []string{})
 
-	chunks := s.analyzer.GetChunkCode(ctx, ids)
+	chunks := s.workspace.GetChunkCode(ctx, ids)
 
 	return mcp.NewToolResultText(chunks), nil
 }
 
 func (s *Server) indexWorkspace(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	go s.analyzer.IndexWorkspace(ctx)
+	go s.workspace.IndexWorkspace(ctx)
 
 	return mcp.NewToolResultText("Indexing in progress..."), nil
 }
 
 func (s *Server) getIndexStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	pendingFiles, lastIndexedAt := s.analyzer.GetIndexStatus()
+	pendingFiles, lastIndexedAt := s.workspace.GetIndexStatus()
 
 	status := fmt.Sprintf("Number of pending files: %d, last indexed: ", pendingFiles)
 	if lastIndexedAt.IsZero() {
@@ -202,12 +462,22 @@ func (s *Server) getIndexStatus(ctx context.Context, request mcp.CallToolRequest
 		status += humanize.Time(lastIndexedAt)
 	}
 
+	recalls := s.workspace.QuantizationRecall()
+	for _, alias := range slices.Sorted(maps.Keys(recalls)) {
+		recall := recalls[alias]
+		repo := ""
+		if alias != "" {
+			repo = " in " + alias
+		}
+		status += fmt.Sprintf(", quantized search recall@10%s: %.1f%% (%.1f%% loss)", repo, 100*recall, 100*(1-recall))
+	}
+
 	return mcp.NewToolResultText(status), nil
 }
 
 func (s *Server) Close() error {
-	if s.analyzer != nil {
-		s.analyzer.Close()
+	if s.workspace != nil {
+		s.workspace.Close()
 	}
 
 	return nil
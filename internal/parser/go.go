@@ -8,9 +8,11 @@ import (
 var GoSpec = &LanguageSpec{
 	NamedChunks: map[string]NamedChunkExtractor{
 		"function_declaration": {
+			Kind:      KindFunction,
 			NameQuery: `(function_declaration name: (identifier) @name)`,
 		},
 		"method_declaration": {
+			Kind:      KindMethod,
 			NameQuery: `(method_declaration name: (field_identifier) @name)`,
 			ParentNameQuery: `
 				(method_declaration
@@ -27,15 +29,18 @@ var GoSpec = &LanguageSpec{
 										(type_identifier) @name))])))`,
 		},
 		"type_declaration": {
+			Kind: KindType,
 			NameQuery: `
 				(type_declaration [
 					(type_spec name: (type_identifier) @name)
 					(type_alias name: (type_identifier) @name)])`,
 		},
 		"var_declaration": {
+			Kind:      KindVar,
 			NameQuery: `(var_declaration (var_spec name: (identifier) @name))`,
 		},
 		"const_declaration": {
+			Kind:      KindConst,
 			NameQuery: `(const_declaration (const_spec name: (identifier) @name))`,
 		},
 	},
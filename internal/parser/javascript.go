@@ -8,21 +8,27 @@ import (
 var JavaScriptSpec = &LanguageSpec{
 	NamedChunks: map[string]NamedChunkExtractor{
 		"function_declaration": {
+			Kind:      KindFunction,
 			NameQuery: `(function_declaration name: (identifier) @name)`,
 		},
 		"generator_function_declaration": {
+			Kind:      KindFunction,
 			NameQuery: `(generator_function_declaration name: (identifier) @name)`,
 		},
 		"class_declaration": {
+			Kind:      KindClass,
 			NameQuery: `(class_declaration name: (identifier) @name)`,
 		},
 		"lexical_declaration": {
+			Kind:      KindVar,
 			NameQuery: `(lexical_declaration (variable_declarator name: (identifier) @name))`,
 		},
 		"variable_declaration": {
+			Kind:      KindVar,
 			NameQuery: `(variable_declaration (variable_declarator name: (identifier) @name))`,
 		},
 		"export_statement": {
+			KindField: "declaration",
 			NameQuery: `(export_statement (declaration name: (identifier) @name))`,
 		},
 	},
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -28,11 +29,31 @@ const (
 	FileTypeIgnore FileType = "ignore"
 )
 
+// ChunkKind is the normalized kind of code entity a chunk holds, shared across languages
+type ChunkKind string
+
+const (
+	KindFunction  ChunkKind = "function"
+	KindMethod    ChunkKind = "method"
+	KindClass     ChunkKind = "class"
+	KindInterface ChunkKind = "interface"
+	KindType      ChunkKind = "type"
+	KindEnum      ChunkKind = "enum"
+	KindVar       ChunkKind = "var"
+	KindConst     ChunkKind = "const"
+	KindModule    ChunkKind = "module"
+	KindComment   ChunkKind = "comment"
+	KindOther     ChunkKind = "other" // content-hashed chunks, e.g. statements or markdown
+)
+
 // File represents a parsed source file with its extracted semantic chunks
 type File struct {
-	Path   string // path within workspace
-	Chunks []*Chunk
-	Source []byte
+	Path     string // path within workspace
+	Language string // language the file was parsed as
+	Chunks   []*Chunk
+	Source   []byte
+	ModTime  int64 // modification time when the file was read, in Unix nanoseconds
+	Size     int64 // size when the file was read
 
 	tree *tree_sitter.Tree
 }
@@ -40,7 +61,9 @@ type File struct {
 // Chunk represents a semantic unit of code extracted from source files
 type Chunk struct {
 	File        string // file path within workspace
+	Language    string
 	Type        string
+	Kind        ChunkKind
 	Path        string // path within file
 	Summary     string
 	Source      string
@@ -65,6 +88,7 @@ func (p *Parser) newChunk(
 	fileType FileType,
 	folded []*tree_sitter.Node,
 	extractor *NamedChunkExtractor,
+	kind ChunkKind,
 ) *Chunk {
 	finalPath := resolvePath(path, usedPaths)
 	startPos, startByte, endPos, endByte := calculateChunkBounds(node, folded)
@@ -85,6 +109,7 @@ func (p *Parser) newChunk(
 	return &Chunk{
 		Path:        finalPath,
 		Type:        string(fileType),
+		Kind:        kind,
 		Summary:     summarize(summaryText),
 		Source:      string(fullText),
 		StartLine:   startPos.Row + 1,
@@ -164,11 +189,25 @@ type LanguageSpec struct {
 	FileTypeRules     []FileTypeRule                 // language-specific file type classification rules
 }
 
+// Fingerprint identifies the chunking behavior of the spec, it changes whenever
+// the queries or rules change, and with them the chunks extracted from a file
+func (s *LanguageSpec) Fingerprint() string {
+	// Maps are encoded with sorted keys, so the encoding is deterministic
+	data, _ := json.Marshal(struct {
+		Spec        *LanguageSpec
+		GlobalRules []FileTypeRule
+	}{s, globalFileTyleRules})
+
+	return fmt.Sprintf("%x", xxhash.Sum64(data))
+}
+
 // NamedChunkExtractor defines tree-sitter queries for extracting named code entities
 type NamedChunkExtractor struct {
-	NameQuery        string // query to extract the entity name
-	ParentNameQuery  string // optional query to extract parent entity name for hierarchical paths
-	SummaryNodeQuery string // optional query to extract a specific node for the summary instead of the main node
+	NameQuery        string    // query to extract the entity name
+	ParentNameQuery  string    // optional query to extract parent entity name for hierarchical paths
+	SummaryNodeQuery string    // optional query to extract a specific node for the summary instead of the main node
+	Kind             ChunkKind // normalized kind of the extracted entity
+	KindField        string    // optional field holding the wrapped node to take the kind from, e.g. for exports
 }
 
 // FileTypeRule defines a pattern-based rule for classifying file types
@@ -188,6 +227,7 @@ var globalFileTyleRules = []FileTypeRule{
 	{Pattern: "doc/**", Type: FileTypeDocs},
 
 	{Pattern: ".git/**", Type: FileTypeIgnore},
+	{Pattern: ".codesearch/**", Type: FileTypeIgnore},
 	{Pattern: "coverage/**", Type: FileTypeIgnore},
 	{Pattern: ".coverage/**", Type: FileTypeIgnore},
 }
@@ -203,6 +243,13 @@ type Parser struct {
 // parse reads and parses a file using tree-sitter, returning the AST and source
 func (p *Parser) parse(filePath string) (*File, error) {
 	fullPath := path.Join(p.workspaceRoot, filePath)
+
+	// Stat before reading, so a write racing the read shows as a newer mtime
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
 	source, err := os.ReadFile(fullPath)
 	if err != nil {
 		return nil, err
@@ -214,9 +261,11 @@ func (p *Parser) parse(filePath string) (*File, error) {
 	}
 
 	return &File{
-		Path:   filePath,
-		Source: source,
-		tree:   tree,
+		Path:    filePath,
+		Source:  source,
+		ModTime: info.ModTime().UnixNano(),
+		Size:    info.Size(),
+		tree:    tree,
 	}, nil
 }
 
@@ -325,7 +374,8 @@ func (p *Parser) createChunkFromNode(
 	if exists {
 		chunkPath, err := p.buildChunkPath(extractor, node, source, parentPath)
 		if err == nil {
-			chunk := p.newChunk(node, source, chunkPath, usedPaths, fileType, folded, &extractor)
+			chunkKind := p.resolveKind(node, extractor)
+			chunk := p.newChunk(node, source, chunkPath, usedPaths, fileType, folded, &extractor, chunkKind)
 			return chunk, chunkPath
 		}
 	}
@@ -334,6 +384,35 @@ func (p *Parser) createChunkFromNode(
 	return p.extractNode(node, source, usedPaths, fileType, folded), parentPath
 }
 
+// resolveKind determines the normalized kind of a named node, looking through
+// wrapper nodes like exports or decorators to the declaration they hold
+func (p *Parser) resolveKind(node *tree_sitter.Node, extractor NamedChunkExtractor) ChunkKind {
+	if extractor.KindField != "" {
+		wrapped := node.ChildByFieldName(extractor.KindField)
+		if wrapped == nil {
+			return KindOther
+		}
+
+		wrappedExtractor, exists := p.spec.NamedChunks[wrapped.Kind()]
+		if !exists {
+			return KindOther
+		}
+
+		return p.resolveKind(wrapped, wrappedExtractor)
+	}
+
+	// JS/TS lexical declarations cover both "let" & "const"
+	if extractor.Kind == KindVar && node.ChildCount() > 0 && node.Child(0).Kind() == "const" {
+		return KindConst
+	}
+
+	if extractor.Kind == "" {
+		return KindOther
+	}
+
+	return extractor.Kind
+}
+
 // extractNode creates a chunk from a node using content-based hashing for the path
 func (p *Parser) extractNode(
 	node *tree_sitter.Node,
@@ -345,7 +424,12 @@ func (p *Parser) extractNode(
 	nodeSource := node.Utf8Text(source)
 	hash := fmt.Sprintf("%x", xxhash.Sum64String(nodeSource))
 
-	return p.newChunk(node, source, hash, usedPaths, fileType, folded, nil)
+	kind := KindOther
+	if slices.Contains(p.spec.FoldIntoNextNode, node.Kind()) {
+		kind = KindComment
+	}
+
+	return p.newChunk(node, source, hash, usedPaths, fileType, folded, nil, kind)
 }
 
 // buildChunkPath constructs a hierarchical path for a named chunk using tree-sitter queries
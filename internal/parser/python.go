@@ -8,12 +8,15 @@ import (
 var PythonSpec = &LanguageSpec{
 	NamedChunks: map[string]NamedChunkExtractor{
 		"function_definition": {
+			Kind:      KindFunction,
 			NameQuery: `(function_definition name: (identifier) @name)`,
 		},
 		"class_definition": {
+			Kind:      KindClass,
 			NameQuery: `(class_definition name: (identifier) @name)`,
 		},
 		"decorated_definition": {
+			KindField: "definition",
 			NameQuery: `(decorated_definition definition: [
 				(function_definition name: (identifier) @name)
 				(class_definition name: (identifier) @name)
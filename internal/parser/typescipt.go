@@ -8,42 +8,55 @@ import (
 var TypeScriptSpec = &LanguageSpec{
 	NamedChunks: map[string]NamedChunkExtractor{
 		"function_declaration": {
+			Kind:      KindFunction,
 			NameQuery: `(function_declaration name: (identifier) @name)`,
 		},
 		"function_signature": {
+			Kind:      KindFunction,
 			NameQuery: `(function_signature name: (identifier) @name)`,
 		},
 		"generator_function_declaration": {
+			Kind:      KindFunction,
 			NameQuery: `(generator_function_declaration name: (identifier) @name)`,
 		},
 		"class_declaration": {
+			Kind:      KindClass,
 			NameQuery: `(class_declaration name: (type_identifier) @name)`,
 		},
 		"abstract_class_declaration": {
+			Kind:      KindClass,
 			NameQuery: `(abstract_class_declaration name: (type_identifier) @name)`,
 		},
 		"interface_declaration": {
+			Kind:      KindInterface,
 			NameQuery: `(interface_declaration name: (type_identifier) @name)`,
 		},
 		"type_alias_declaration": {
+			Kind:      KindType,
 			NameQuery: `(type_alias_declaration name: (type_identifier) @name)`,
 		},
 		"lexical_declaration": {
+			Kind:      KindVar,
 			NameQuery: `(lexical_declaration (variable_declarator name: (identifier) @name))`,
 		},
 		"variable_declaration": {
+			Kind:      KindVar,
 			NameQuery: `(variable_declaration (variable_declarator name: (identifier) @name))`,
 		},
 		"ambient_declaration": {
+			Kind:      KindVar,
 			NameQuery: `(ambient_declaration (variable_declaration (variable_declarator name: (identifier) @name)))`,
 		},
 		"export_statement": {
+			KindField: "declaration",
 			NameQuery: `(export_statement (declaration name: (identifier) @name))`,
 		},
 		"enum_declaration": {
+			Kind:      KindEnum,
 			NameQuery: `(enum_declaration name: (identifier) @name)`,
 		},
 		"module": {
+			Kind:      KindModule,
 			NameQuery: `(module name: (identifier) @name)`,
 		},
 	},
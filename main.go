@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"strings"
 
 	_ "embed"
 
+	"github.com/suvaidkhan/code-explore-mcp/internal/analyzer"
+	"github.com/suvaidkhan/code-explore-mcp/internal/eval"
+	"github.com/suvaidkhan/code-explore-mcp/internal/index"
 	"github.com/suvaidkhan/code-explore-mcp/internal/mcp"
 )
 
@@ -15,12 +21,21 @@ var Version string
 func main() {
 	Version = strings.TrimSpace(Version)
 
-	workspaceRoot := os.Getenv("CODE_SEARCH_WORKSPACE_ROOT")
-	if workspaceRoot == "" {
-		workspaceRoot = "."
+	repos, err := analyzer.ReposFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid workspace roots: %v", err)
+	}
+
+	if len(os.Args) > 1 {
+		// Commands return their errors, so deferred closes run before exiting
+		err := runCommand(os.Args[1], os.Args[2:], repos)
+		if err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
 	}
 
-	server, err := mcp.NewServer(workspaceRoot, Version)
+	server, err := mcp.NewServer(repos, Version, index.ConfigFromEnv())
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
@@ -30,3 +45,123 @@ func main() {
 		log.Fatalf("Server error: %v", err)
 	}
 }
+
+// runCommand runs a maintenance command instead of the server:
+//
+//	export <archive>          index the workspace & write a snapshot of the index
+//	import <archive>          replace the workspace's index with a snapshot
+//	eval [--json] <golden>    index the workspace & score searches against a golden set
+func runCommand(command string, args []string, repos []analyzer.Repo) error {
+	usage := map[string]string{
+		"export": "export <archive>",
+		"import": "import <archive>",
+		"eval":   "eval [--json] <golden.json|golden.yaml>",
+	}
+	if _, exists := usage[command]; !exists {
+		return fmt.Errorf("unknown command")
+	}
+
+	jsonOutput := command == "eval" && len(args) > 0 && args[0] == "--json"
+	if jsonOutput {
+		args = args[1:]
+	}
+
+	if len(args) != 1 {
+		return fmt.Errorf("usage: code-search-mcp %s", usage[command])
+	}
+
+	if len(repos) > 1 {
+		return fmt.Errorf("the command works on one repository, set CODE_SEARCH_WORKSPACE_ROOT instead of CODE_SEARCH_WORKSPACE_ROOTS")
+	}
+
+	ctx := context.Background()
+	workspaceRoot := repos[0].Root
+	cfg := index.ConfigFromEnv()
+
+	switch command {
+	case "export":
+		return exportIndex(ctx, workspaceRoot, cfg, args[0])
+	case "import":
+		return importIndex(ctx, workspaceRoot, cfg, args[0])
+	default:
+		return evaluate(ctx, workspaceRoot, cfg, args[0], jsonOutput)
+	}
+}
+
+// exportIndex indexes the workspace & writes a snapshot of its index, removing
+// the archive if the export fails
+func exportIndex(ctx context.Context, workspaceRoot string, cfg index.Config, path string) error {
+	a, err := analyzer.Build(ctx, workspaceRoot, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to index workspace: %w", err)
+	}
+	defer a.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	snapshot, err := a.ExportIndex(ctx, file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write snapshot: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to export index: %w", err)
+	}
+
+	log.Printf("Exported index of commit %s to %s", snapshot.SourceCommit, path)
+	return nil
+}
+
+// importIndex replaces the workspace's index with a snapshot
+func importIndex(ctx context.Context, workspaceRoot string, cfg index.Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	snapshot, err := index.Import(ctx, workspaceRoot, cfg, file)
+	if err != nil {
+		return fmt.Errorf("failed to import index: %w", err)
+	}
+
+	log.Printf("Imported index of commit %s, built %s", snapshot.SourceCommit, snapshot.CreatedAt.Format("2006-01-02 15:04"))
+	return nil
+}
+
+// evaluate indexes the workspace & scores searches against a golden set, failing
+// when a mean score is below its threshold
+func evaluate(ctx context.Context, workspaceRoot string, cfg index.Config, path string, jsonOutput bool) error {
+	set, err := eval.Load(path)
+	if err != nil {
+		return fmt.Errorf("failed to load golden set: %w", err)
+	}
+
+	a, err := analyzer.Build(ctx, workspaceRoot, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to index workspace: %w", err)
+	}
+	defer a.Close()
+
+	report, err := eval.Run(ctx, set, a.SemanticSearch)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate search: %w", err)
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		report.Print(os.Stdout)
+	}
+
+	if failures := report.Check(set.Thresholds); len(failures) > 0 {
+		return fmt.Errorf("search quality is below the thresholds: %s", strings.Join(failures, ", "))
+	}
+
+	return nil
+}